@@ -0,0 +1,313 @@
+package arc
+
+import (
+	"Learning_Code/geecache/lru"
+	"container/list"
+	"time"
+)
+
+// 参考：Megiddo & Modha, "ARC: A Self-Tuning, Low Overhead Replacement Cache"
+
+// ARC cache，对并发访问不安全
+// t1保存只访问过一次的条目，t2保存访问过多次的条目；b1、b2分别记录从t1、t2淘汰的key（ghost）。
+// 命中b1说明t1过小，命中b2说明t2过小，据此自适应地调整t1的目标容量p。
+// 原论文以条目个数计算容量，这里统一改为以字节计算。
+type Cache struct {
+	maxBytes int64 // 最大容量
+	p        int64 // t1的目标容量
+	t1Bytes  int64
+	t2Bytes  int64
+	b1Bytes  int64
+	b2Bytes  int64
+	t1       *list.List
+	t2       *list.List
+	b1       *list.List
+	b2       *list.List
+	cache    map[string]*list.Element // t1和t2中的条目
+	ghosts   map[string]*list.Element // b1和b2中的条目
+	// 可选属性，在删除条目时执行的回调函数
+	OnEvicted func(key string, value Value)
+}
+
+// 与lru包共用Value定义，使不同淘汰策略可以互相替换
+type Value = lru.Value
+
+type entry struct {
+	key    string
+	value  Value
+	expire time.Time // 过期时间，零值表示永不过期
+	inT2   bool      // 是否位于t2
+}
+
+// 判断条目在now时刻是否已过期
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && now.After(e.expire)
+}
+
+func (e *entry) size() int64 {
+	return int64(len(e.key)) + int64(e.value.Len())
+}
+
+// ghost中的条目，只保留key和条目大小
+type ghostEntry struct {
+	key  string
+	size int64
+	inB2 bool // 是否位于b2
+}
+
+// 用于实例化的New()函数
+func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		t1:        list.New(),
+		t2:        list.New(),
+		b1:        list.New(),
+		b2:        list.New(),
+		cache:     make(map[string]*list.Element),
+		ghosts:    make(map[string]*list.Element),
+		OnEvicted: onEvicted,
+	}
+}
+
+// 查找功能，t1中的条目再次命中时移动到t2
+func (c *Cache) Get(key string) (value Value, ok bool) {
+	elem, ok := c.cache[key]
+	if !ok {
+		return
+	}
+	e := elem.Value.(*entry)
+	// 惰性过期：查找时发现条目已过期，直接删除并视为未命中
+	if e.expired(time.Now()) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.promote(elem)
+	return e.value, true
+}
+
+// 新增/修改，条目永不过期
+func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// 新增/修改，条目在expire时刻之后过期，expire为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
+	inB2 := false
+	if elem, ok := c.cache[key]; ok {
+		// 修改视为一次访问
+		e := elem.Value.(*entry)
+		c.resize(e, int64(value.Len())-int64(e.value.Len()))
+		e.value = value
+		e.expire = expire
+		c.promote(elem)
+	} else {
+		e := &entry{key: key, value: value, expire: expire}
+		if elem, ok := c.ghosts[key]; ok {
+			// 命中ghost，按b1、b2的大小比例调整p，并将条目直接放入t2
+			g := elem.Value.(*ghostEntry)
+			inB2 = g.inB2
+			c.adapt(g)
+			c.removeGhost(elem)
+			e.inT2 = true
+			c.cache[key] = c.t2.PushFront(e)
+			c.t2Bytes += e.size()
+		} else {
+			c.cache[key] = c.t1.PushFront(e)
+			c.t1Bytes += e.size()
+		}
+	}
+
+	// 如果超过Cache最大容量，需要删除条目
+	for c.maxBytes != 0 && c.maxBytes < c.t1Bytes+c.t2Bytes {
+		c.replace(inB2)
+	}
+}
+
+// 缓存淘汰，相当于论文中的REPLACE：t1超出目标容量p时淘汰t1最旧的条目，否则淘汰t2最旧的条目
+func (c *Cache) RemoveOldest() {
+	c.replace(false)
+}
+
+// 删除key对应的条目，key不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if elem, ok := c.cache[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// 清理所有已过期的条目，供后台清理协程定期调用
+func (c *Cache) RemoveExpired() {
+	now := time.Now()
+	for _, elem := range c.cache {
+		if elem.Value.(*entry).expired(now) {
+			c.removeElement(elem)
+		}
+	}
+}
+
+// 按淘汰顺序遍历未过期的条目：先从旧到新遍历t1，再从旧到新遍历t2，fn返回false时停止遍历
+func (c *Cache) Range(fn func(key string, value Value, expire time.Time) bool) {
+	now := time.Now()
+	for _, l := range []*list.List{c.t1, c.t2} {
+		for elem := l.Back(); elem != nil; elem = elem.Prev() {
+			e := elem.Value.(*entry)
+			if e.expired(now) {
+				continue
+			}
+			if !fn(e.key, e.value, e.expire) {
+				return
+			}
+		}
+	}
+}
+
+// 返回加入key/value时首先会被淘汰的条目的key，不需要淘汰或key已存在时ok为false，供准入策略使用。
+// 新条目自身会被首先淘汰时也返回false
+func (c *Cache) Victim(key string, value Value) (victim string, ok bool) {
+	if _, exist := c.cache[key]; exist || c.maxBytes == 0 {
+		return
+	}
+	size := int64(len(key)) + int64(value.Len())
+	if c.t1Bytes+c.t2Bytes+size <= c.maxBytes {
+		return
+	}
+	// 按加入新条目之后的状态，模拟 replace 的选择
+	p, inB2 := c.p, false
+	t1Bytes, t1Len, t2Len := c.t1Bytes, c.t1.Len(), c.t2.Len()
+	if elem, ok := c.ghosts[key]; ok {
+		g := elem.Value.(*ghostEntry)
+		p, inB2 = c.adapted(g), g.inB2
+		t2Len++
+	} else {
+		t1Bytes += size
+		t1Len++
+	}
+	elem := c.t2.Back()
+	if t1Len > 0 && (t1Bytes > p || (inB2 && t1Bytes == p) || t2Len == 0) {
+		elem = c.t1.Back()
+	}
+	if elem == nil {
+		return
+	}
+	return elem.Value.(*entry).key, true
+}
+
+// 返回已用容量
+func (c *Cache) Bytes() int64 {
+	return c.t1Bytes + c.t2Bytes
+}
+
+// 返回已经插入的条目数量
+func (c *Cache) Len() int {
+	return c.t1.Len() + c.t2.Len()
+}
+
+func (c *Cache) replace(inB2 bool) {
+	if c.t1.Len() > 0 && (c.t1Bytes > c.p || (inB2 && c.t1Bytes == c.p) || c.t2.Len() == 0) {
+		elem := c.t1.Back()
+		c.removeElement(elem)
+		c.addGhost(elem.Value.(*entry))
+		return
+	}
+	if elem := c.t2.Back(); elem != nil {
+		c.removeElement(elem)
+		c.addGhost(elem.Value.(*entry))
+	}
+}
+
+// 根据命中的ghost调整t1的目标容量p
+func (c *Cache) adapt(g *ghostEntry) {
+	c.p = c.adapted(g)
+}
+
+// 返回命中ghost后t1的目标容量，不修改p
+func (c *Cache) adapted(g *ghostEntry) int64 {
+	b1Len, b2Len := int64(c.b1.Len()), int64(c.b2.Len())
+	if !g.inB2 {
+		delta := g.size
+		if b2Len > b1Len {
+			delta = g.size * b2Len / b1Len
+		}
+		if c.maxBytes != 0 && c.p+delta > c.maxBytes {
+			return c.maxBytes
+		}
+		return c.p + delta
+	}
+	delta := g.size
+	if b1Len > b2Len {
+		delta = g.size * b1Len / b2Len
+	}
+	if c.p < delta {
+		return 0
+	}
+	return c.p - delta
+}
+
+// 将命中的条目移动到t2队首
+func (c *Cache) promote(elem *list.Element) {
+	e := elem.Value.(*entry)
+	if e.inT2 {
+		c.t2.MoveToFront(elem)
+		return
+	}
+	c.t1.Remove(elem)
+	c.t1Bytes -= e.size()
+	e.inT2 = true
+	c.cache[e.key] = c.t2.PushFront(e)
+	c.t2Bytes += e.size()
+}
+
+// 调整条目所在队列的已用容量
+func (c *Cache) resize(e *entry, delta int64) {
+	if e.inT2 {
+		c.t2Bytes += delta
+	} else {
+		c.t1Bytes += delta
+	}
+}
+
+// 从所在队列和字典中删除条目，并更新已用容量
+func (c *Cache) removeElement(elem *list.Element) {
+	e := elem.Value.(*entry)
+	if e.inT2 {
+		c.t2.Remove(elem)
+	} else {
+		c.t1.Remove(elem)
+	}
+	c.resize(e, -e.size())
+	delete(c.cache, e.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// 将被淘汰的key记入对应的ghost，ghost总大小不超过maxBytes，超出时优先丢弃较大一侧最旧的记录
+func (c *Cache) addGhost(e *entry) {
+	g := &ghostEntry{key: e.key, size: e.size(), inB2: e.inT2}
+	if g.inB2 {
+		c.ghosts[e.key] = c.b2.PushFront(g)
+		c.b2Bytes += g.size
+	} else {
+		c.ghosts[e.key] = c.b1.PushFront(g)
+		c.b1Bytes += g.size
+	}
+	for c.b1Bytes+c.b2Bytes > c.maxBytes {
+		if c.b1Bytes > c.b2Bytes {
+			c.removeGhost(c.b1.Back())
+		} else {
+			c.removeGhost(c.b2.Back())
+		}
+	}
+}
+
+func (c *Cache) removeGhost(elem *list.Element) {
+	g := elem.Value.(*ghostEntry)
+	if g.inB2 {
+		c.b2.Remove(elem)
+		c.b2Bytes -= g.size
+	} else {
+		c.b1.Remove(elem)
+		c.b1Bytes -= g.size
+	}
+	delete(c.ghosts, g.key)
+}
@@ -0,0 +1,117 @@
+package arc
+
+import (
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+func TestCache_Get(t *testing.T) {
+	arc := New(int64(0), nil)
+	arc.Add("key1", String("1234"))
+	if v, ok := arc.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := arc.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+// 一次性扫描的key不会把被重复访问的热点key挤出缓存
+func TestScanResistance(t *testing.T) {
+	// 每个条目4字节，容量可容纳4个条目
+	arc := New(int64(16), nil)
+	arc.Add("h1", String("v1"))
+	arc.Add("h2", String("v2"))
+	arc.Get("h1")
+	arc.Get("h2")
+	for _, k := range []string{"s1", "s2", "s3", "s4", "s5"} {
+		arc.Add(k, String("vv"))
+	}
+
+	if _, ok := arc.Get("h1"); !ok {
+		t.Fatalf("hot key h1 should survive the scan")
+	}
+	if _, ok := arc.Get("h2"); !ok {
+		t.Fatalf("hot key h2 should survive the scan")
+	}
+	if arc.Len() != 4 || arc.t1Bytes+arc.t2Bytes > 16 {
+		t.Fatalf("cache should hold 4 entries within 16 bytes, got %d entries", arc.Len())
+	}
+}
+
+// 命中b1说明t1过小，需要增大t1的目标容量p
+func TestAdapt(t *testing.T) {
+	arc := New(int64(16), nil)
+	for _, k := range []string{"k1", "k2", "k3", "k4", "k5"} {
+		arc.Add(k, String("vv"))
+	}
+	if elem, ok := arc.ghosts["k1"]; !ok || elem.Value.(*ghostEntry).inB2 {
+		t.Fatalf("k1 should be remembered in b1")
+	}
+	arc.Add("k1", String("vv"))
+	if arc.p != 4 {
+		t.Fatalf("p should grow to 4 after a b1 hit, got %d", arc.p)
+	}
+	if e := arc.cache["k1"].Value.(*entry); !e.inT2 {
+		t.Fatalf("k1 should be added to t2")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	arc := New(int64(0), nil)
+	arc.AddWithExpire("key1", String("1"), time.Now().Add(-time.Second))
+	arc.AddWithExpire("key2", String("2"), time.Now().Add(-time.Second))
+	arc.Add("key3", String("3"))
+
+	if _, ok := arc.Get("key1"); ok || arc.Len() != 2 {
+		t.Fatalf("lazy expire key1 failed")
+	}
+	arc.RemoveExpired()
+	if _, ok := arc.Get("key3"); !ok || arc.Len() != 1 {
+		t.Fatalf("RemoveExpired failed")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	arc := New(int64(0), nil)
+	arc.Add("key1", String("1234"))
+	arc.Add("key2", String("5678"))
+	arc.Remove("key1")
+	arc.Remove("key3")
+	if _, ok := arc.Get("key1"); ok || arc.Len() != 1 {
+		t.Fatalf("Remove key1 failed")
+	}
+}
+
+func TestVictim(t *testing.T) {
+	keys := make([]string, 0)
+	callback := func(key string, value Value) {
+		keys = append(keys, key)
+	}
+	arc := New(int64(16), callback)
+	for _, k := range []string{"k1", "k2", "k3"} {
+		arc.Add(k, String("vv"))
+	}
+	if _, ok := arc.Victim("k4", String("vv")); ok {
+		t.Fatalf("no victim expected while there is room")
+	}
+	arc.Add("k4", String("vv"))
+
+	victim, ok := arc.Victim("k5", String("vv"))
+	arc.Add("k5", String("vv"))
+	if !ok || victim != "k1" || len(keys) != 1 || keys[0] != "k1" {
+		t.Fatalf("victim should be k1, got %s, evicted %s", victim, keys)
+	}
+	// 命中b1后p增大为4，t1仍超出目标容量，淘汰t1中最旧的k2
+	victim, ok = arc.Victim("k1", String("vv"))
+	arc.Add("k1", String("vv"))
+	if !ok || victim != "k2" || len(keys) != 2 || keys[1] != "k2" || arc.p != 4 {
+		t.Fatalf("victim should be k2, got %s, evicted %s", victim, keys)
+	}
+}
@@ -0,0 +1,201 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// BatchGetter 是 Getter 的可选扩展，数据源可以一次加载多个key时实现该接口，
+// GetMulti 会把属于本节点的未命中key合并为一次调用。返回的map中不包含的key视为不存在
+type BatchGetter interface {
+	GetMulti(ctx context.Context, keys []string) (map[string][]byte, error)
+}
+
+// BatchGetterFunc 同时实现了 Getter 和 BatchGetter 接口，可以直接传给 NewGroup
+type BatchGetterFunc func(ctx context.Context, keys []string) (map[string][]byte, error)
+
+// GetMulti 实现 BatchGetter 的接口函数
+func (f BatchGetterFunc) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	return f(ctx, keys)
+}
+
+// Get 实现 Getter 的接口函数，加载单个key
+func (f BatchGetterFunc) Get(key string) ([]byte, error) {
+	values, err := f(context.Background(), []string{key})
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := values[key]; ok {
+		return v, nil
+	}
+	return nil, ErrNotFound
+}
+
+// GetMulti 批量获取多个key，使用 context.Background()
+func (g *Group) GetMulti(keys []string) (map[string]ByteView, error) {
+	return g.GetMultiContext(context.Background(), keys)
+}
+
+// GetMultiContext 批量获取多个key。未命中的key按所属节点分组：支持 PeerBatchGetter 的远程节点每个只请求一次，
+// 属于本节点的key在 getter 实现了 BatchGetter 时一次加载，其余的key逐个加载，各组并发进行。
+// 返回的map只包含获取成功的key，不存在的key不视为错误；其他错误中的第一个会被返回，同时仍返回其余key的结果
+func (g *Group) GetMultiContext(ctx context.Context, keys []string) (map[string]ByteView, error) {
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("key is required")
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		result   = make(map[string]ByteView, len(keys))
+		local    []string                           // 属于本节点的key
+		single   []string                           // 需要逐个加载的key
+		byPeer   = make(map[PeerGetter][]string)    // 按远程节点分组的key
+		seen     = make(map[string]bool, len(keys)) // 去掉重复的key
+	)
+	// 记录一个key的结果，可能在多个协程中调用
+	done := func(key string, value ByteView, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			result[key] = value
+		} else if !errors.Is(err, ErrNotFound) && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	_, batchGetter := g.getter.(BatchGetter)
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		g.stats.Gets.Add(1)
+		if v, ok := g.lookupCache(key); ok {
+			g.stats.CacheHits.Add(1)
+			g.maybeRefresh(key, v)
+			result[key] = v
+			continue
+		}
+		if err, ok := g.negCache.get(key); ok {
+			g.stats.NegativeHits.Add(1)
+			done(key, ByteView{}, err)
+			continue
+		}
+		g.stats.Loads.Add(1)
+
+		if g.peers != nil && !isLocalLoad(ctx) {
+			if peer, ok := g.peers.PickPeer(key); ok {
+				if _, ok := peer.(PeerBatchGetter); ok {
+					byPeer[peer] = append(byPeer[peer], key)
+				} else {
+					single = append(single, key)
+				}
+				continue
+			}
+		}
+		if batchGetter {
+			local = append(local, key)
+		} else {
+			single = append(single, key)
+		}
+	}
+
+	for peer, keys := range byPeer {
+		wg.Add(1)
+		go func(peer PeerBatchGetter, keys []string) {
+			defer wg.Done()
+			g.getManyFromPeer(ctx, peer, keys, done)
+		}(peer.(PeerBatchGetter), keys)
+	}
+	for _, key := range single {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			value, err := g.load(ctx, key)
+			done(key, value, err)
+		}(key)
+	}
+	if len(local) > 0 {
+		g.getManyLocally(ctx, local, done)
+	}
+	wg.Wait()
+
+	return result, firstErr
+}
+
+// 一次请求从远程节点获取多个key，失败时与 load 一样回退到本地加载
+func (g *Group) getManyFromPeer(ctx context.Context, peer PeerBatchGetter, keys []string,
+	done func(key string, value ByteView, err error)) {
+	g.stats.LoadsDeduped.Add(1)
+	values, err := peer.GetMulti(ctx, g.name, keys)
+	if err != nil {
+		g.stats.PeerErrors.Add(1)
+		log.Println("[GeeCache] Failed to get multi from peer", err)
+		g.getManyLocally(ctx, keys, done)
+		return
+	}
+	for _, key := range keys {
+		g.stats.PeerLoads.Add(1)
+		bytes, ok := values[key]
+		if !ok {
+			// 远程节点确认key不存在
+			g.negCache.add(key, ErrNotFound)
+			done(key, ByteView{}, ErrNotFound)
+			continue
+		}
+		done(key, g.peerValue(key, bytes), nil)
+	}
+}
+
+// 从本地数据源加载多个key，getter 实现了 BatchGetter 时只调用一次，否则逐个经过singleflight加载
+func (g *Group) getManyLocally(ctx context.Context, keys []string,
+	done func(key string, value ByteView, err error)) {
+	bg, ok := g.getter.(BatchGetter)
+	if !ok {
+		for _, key := range keys {
+			viewi, err, _ := g.loader.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+				g.stats.LoadsDeduped.Add(1)
+				return g.loadLocally(ctx, key)
+			})
+			if err != nil {
+				done(key, ByteView{}, err)
+				continue
+			}
+			done(key, viewi.(ByteView), nil)
+		}
+		return
+	}
+
+	g.stats.LoadsDeduped.Add(1)
+	values, err := bg.GetMulti(ctx, keys)
+	for _, key := range keys {
+		if err != nil {
+			g.stats.LocalLoadErrs.Add(1)
+			// 调用方取消导致的错误与key无关，不缓存
+			if ctx.Err() == nil {
+				g.negCache.add(key, err)
+			}
+			done(key, ByteView{}, err)
+			continue
+		}
+		bytes, ok := values[key]
+		if !ok {
+			g.stats.LocalLoadErrs.Add(1)
+			g.negCache.add(key, ErrNotFound)
+			done(key, ByteView{}, ErrNotFound)
+			continue
+		}
+		g.stats.LocalLoads.Add(1)
+		value := ByteView{b: cloneBytes(bytes), e: g.expireAt()}
+		g.populateCache(key, value)
+		done(key, value, nil)
+	}
+}
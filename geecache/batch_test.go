@@ -0,0 +1,130 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// 测试属于本节点的未命中key合并为一次 BatchGetter 调用
+func TestGetMultiLocal(t *testing.T) {
+	var calls [][]string
+	gee := NewGroup("batch", 2<<10, BatchGetterFunc(
+		func(ctx context.Context, keys []string) (map[string][]byte, error) {
+			calls = append(calls, keys)
+			values := make(map[string][]byte)
+			for _, key := range keys {
+				if v, ok := db[key]; ok {
+					values[key] = []byte(v)
+				}
+			}
+			return values, nil
+		}))
+
+	gee.Get("Tom")
+	views, err := gee.GetMulti([]string{"Tom", "Jack", "Sam", "Jack", "unknow"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(views) != 3 || views["Tom"].String() != "630" || views["Jack"].String() != "589" || views["Sam"].String() != "567" {
+		t.Fatalf("unexpected result %v", views)
+	}
+	if len(calls) != 2 || !reflect.DeepEqual(calls[1], []string{"Jack", "Sam", "unknow"}) {
+		t.Fatalf("expect one batch for the missing keys, got %v", calls)
+	}
+	//批量加载的值进入缓存
+	if _, err := gee.GetMulti([]string{"Jack", "Sam"}); err != nil || len(calls) != 2 {
+		t.Fatalf("batch loaded values should be cached")
+	}
+
+	if _, err := gee.GetMulti([]string{"Tom", ""}); err == nil {
+		t.Fatalf("empty key should be rejected")
+	}
+}
+
+type batchPeer struct {
+	batches [][]string
+}
+
+func (p *batchPeer) PickPeer(key string) (PeerGetter, bool) {
+	// Tom 属于本节点
+	return p, key != "Tom"
+}
+
+func (p *batchPeer) Get(group string, key string) ([]byte, error) {
+	return nil, errors.New("should not be called")
+}
+
+func (p *batchPeer) GetMulti(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	p.batches = append(p.batches, keys)
+	values := make(map[string][]byte)
+	for _, key := range keys {
+		if key != "unknow" {
+			values[key] = []byte("peer-" + key)
+		}
+	}
+	return values, nil
+}
+
+// 测试属于远程节点的key合并为一次 PeerBatchGetter 调用
+func TestGetMultiPeer(t *testing.T) {
+	gee := NewGroup("batch-peer", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	peer := &batchPeer{}
+	gee.RegisterPeers(peer)
+
+	views, err := gee.GetMulti([]string{"Tom", "Jack", "Sam", "unknow"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(views) != 3 || views["Tom"].String() != "Tom" || views["Jack"].String() != "peer-Jack" {
+		t.Fatalf("unexpected result %v", views)
+	}
+	if len(peer.batches) != 1 || len(peer.batches[0]) != 3 {
+		t.Fatalf("expect one batch to the peer, got %v", peer.batches)
+	}
+	if st := gee.Stats(); st.PeerLoads != 3 || st.LocalLoads != 1 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+// 测试通过HTTP协议批量获取远程节点的值
+func TestHTTPGetMulti(t *testing.T) {
+	NewGroup("http-batch", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			if v, ok := db[key]; ok {
+				return []byte(v), nil
+			}
+			return nil, ErrNotFound
+		}))
+
+	posts := 0
+	peers := NewHTTPPool("")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posts++
+		}
+		peers.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath}
+
+	values, err := h.GetMulti(context.Background(), "http-batch", []string{"Tom", "Jack", "unknow"})
+	if err != nil {
+		t.Fatalf("failed to get multi from peer: %v", err)
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"Jack", "Tom"}) || string(values["Tom"]) != "630" || posts != 1 {
+		t.Fatalf("unexpected result %v after %d requests", values, posts)
+	}
+}
@@ -0,0 +1,104 @@
+package geecache
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// 连续失败多少次后断开
+	defaultBreakerThreshold = 5
+	// 断开后经过多长时间允许试探请求
+	defaultBreakerCoolDown = 10 * time.Second
+)
+
+// errCircuitOpen 表示远程节点的熔断器已断开，请求没有发出
+var errCircuitOpen = errors.New("geecache: circuit breaker is open")
+
+// 熔断器的状态，数值同时用于导出监控指标
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // 正常访问
+	breakerOpen                         // 不再访问，直到冷却时间结束
+	breakerHalfOpen                     // 冷却结束，只允许一个试探请求
+)
+
+// breaker 是单个远程节点的熔断器：连续失败threshold次后断开，coolDown后放行一个试探请求，
+// 试探成功则恢复，失败则重新断开。threshold小于等于0时不启用
+type breaker struct {
+	threshold int
+	coolDown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int       // 连续失败的次数
+	openedAt time.Time // 最近一次断开或放行试探请求的时间
+	opens    AtomicInt // 断开的次数
+}
+
+func newBreaker(threshold int, coolDown time.Duration) *breaker {
+	return &breaker{threshold: threshold, coolDown: coolDown}
+}
+
+// ready 返回节点当前是否可以被选中，不改变熔断器的状态
+func (b *breaker) ready() bool {
+	if b == nil || b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == breakerClosed || time.Since(b.openedAt) >= b.coolDown
+}
+
+// allow 在发出请求前调用，返回false时不应发出请求。
+// 冷却结束后只放行一个试探请求，试探请求没有结果时，再过coolDown放行下一个
+func (b *breaker) allow() bool {
+	if b == nil || b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerClosed {
+		return true
+	}
+	if time.Since(b.openedAt) < b.coolDown {
+		return false
+	}
+	b.state = breakerHalfOpen
+	b.openedAt = time.Now()
+	return true
+}
+
+// record 记录一次请求的结果，failed表示节点不可用（网络错误、超时或5xx）
+func (b *breaker) record(failed bool) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		if b.state != breakerOpen {
+			b.opens.Add(1)
+		}
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// 返回当前状态，用于导出监控指标
+func (b *breaker) current() breakerState {
+	if b == nil {
+		return breakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,151 @@
+package geecache
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试熔断器在连续失败后断开，冷却后放行一个试探请求
+func TestBreaker(t *testing.T) {
+	b := newBreaker(2, 20*time.Millisecond)
+	b.record(true)
+	if !b.allow() || b.current() != breakerClosed {
+		t.Fatalf("breaker should stay closed below the threshold")
+	}
+	b.record(false)
+	b.record(true)
+	if b.current() != breakerClosed {
+		t.Fatalf("a success should reset the failure count")
+	}
+	b.record(true)
+	if b.ready() || b.allow() || b.current() != breakerOpen {
+		t.Fatalf("breaker should open after 2 consecutive failures")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.ready() || !b.allow() || b.current() != breakerHalfOpen {
+		t.Fatalf("breaker should let a probe through after the cool-down")
+	}
+	if b.allow() {
+		t.Fatalf("only one probe should be allowed")
+	}
+	b.record(true)
+	if b.current() != breakerOpen || b.opens.Get() != 2 {
+		t.Fatalf("a failed probe should open the breaker again, opens = %d", b.opens.Get())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	b.allow()
+	b.record(false)
+	if b.current() != breakerClosed || !b.allow() {
+		t.Fatalf("a successful probe should close the breaker")
+	}
+}
+
+// 测试 PickPeer 跳过熔断器断开的节点，节点恢复后重新选中
+func TestPickPeerSkipsOpenBreaker(t *testing.T) {
+	var down int32 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("630"))
+	}))
+	defer srv.Close()
+
+	pool := NewHTTPPool("", WithPeerCircuitBreaker(2, 20*time.Millisecond))
+	pool.Set(srv.URL)
+	for i := 0; i < 2; i++ {
+		peer, ok := pool.PickPeer("Tom")
+		if !ok {
+			t.Fatalf("peer should be picked before the breaker opens")
+		}
+		if _, err := peer.Get("scores", "Tom"); err == nil {
+			t.Fatalf("expect an error from a peer that is down")
+		}
+	}
+	if _, ok := pool.PickPeer("Tom"); ok {
+		t.Fatalf("peer with an open breaker should be skipped")
+	}
+
+	var metrics strings.Builder
+	pool.writePeerMetrics(&metrics)
+	for _, line := range []string{
+		`geecache_peer_circuit_state{peer="` + srv.URL + `"} 1`,
+		`geecache_peer_circuit_opens_total{peer="` + srv.URL + `"} 1`,
+	} {
+		if !strings.Contains(metrics.String(), line+"\n") {
+			t.Fatalf("metrics should contain %q, got:\n%s", line, metrics.String())
+		}
+	}
+
+	//冷却结束后，试探请求成功，节点恢复
+	time.Sleep(30 * time.Millisecond)
+	atomic.StoreInt32(&down, 0)
+	peer, ok := pool.PickPeer("Tom")
+	if !ok {
+		t.Fatalf("peer should be picked after the cool-down")
+	}
+	if b, err := peer.Get("scores", "Tom"); err != nil || string(b) != "630" {
+		t.Fatalf("probe should succeed, got %q, %v", b, err)
+	}
+	if _, ok := pool.PickPeer("Tom"); !ok {
+		t.Fatalf("peer should be picked after a successful probe")
+	}
+}
+
+// 测试主节点的熔断器断开时，写入和删除不会发给其他节点或只在本地执行，而是返回 errCircuitOpen
+func TestWritesWithOpenBreaker(t *testing.T) {
+	var writes, fallbacks int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			atomic.AddInt32(&writes, 1)
+		} else if r.Header.Get(fallbackHeader) != "" {
+			atomic.AddInt32(&fallbacks, 1)
+		}
+	}))
+	defer srv.Close()
+
+	pool := NewHTTPPool("", WithPeerFallback(1), WithPeerCircuitBreaker(1, time.Minute))
+	pool.Set("http://owner.invalid", srv.URL)
+	key := ""
+	for i := 0; key == ""; i++ {
+		if k := "key" + strconv.Itoa(i); pool.peers.Get(k) == "http://owner.invalid" {
+			key = k
+		}
+	}
+	pool.httpGetters["http://owner.invalid"].breaker.record(true)
+
+	//读取跳过主节点，写入和删除仍然发给主节点
+	peer, ok := pool.PickPeer(key)
+	if !ok || peer.(*peerChain).getters[0].node != srv.URL {
+		t.Fatalf("reads should fall back to %s", srv.URL)
+	}
+	//第一个请求就发给了代替主节点的节点，需要通知它从本地加载，而不是再转发给主节点
+	if _, err := peer.Get("scores", key); err != nil || atomic.LoadInt32(&fallbacks) != 1 {
+		t.Fatalf("the first request should carry the fallback header, err = %v", err)
+	}
+	if err := peer.(PeerRemover).Remove("scores", key); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("expect errCircuitOpen from the chain, got %v", err)
+	}
+
+	db := &fakeDB{data: map[string]string{}}
+	gee := NewGroup("breaker-writes", 2<<10, db, WithSetter(db, WriteThrough))
+	gee.RegisterPeers(pool)
+	if err := gee.Remove(key); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("expect errCircuitOpen from Remove, got %v", err)
+	}
+	if err := gee.Set(key, []byte("630")); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("expect errCircuitOpen from Set, got %v", err)
+	}
+	if db.value(key) != "" || atomic.LoadInt32(&writes) != 0 {
+		t.Fatalf("writes should not reach other nodes, db = %q, writes = %d", db.value(key), writes)
+	}
+}
@@ -1,9 +1,12 @@
 package geecache
 
+import "time"
+
 //抽象一个只读数据结构表示缓存值
 type ByteView struct {
 	//选择 byte 类型是为了能够支持任意的数据类型的存储，例如字符串、图片等。
 	b []byte
+	e time.Time // 过期时间，零值表示永不过期
 }
 
 //实现ByteView的Len()方法
@@ -11,6 +14,11 @@ func (v ByteView) Len() int {
 	return len(v.b)
 }
 
+//Expire方法返回ByteView的过期时间，零值表示永不过期
+func (v ByteView) Expire() time.Time {
+	return v.e
+}
+
 //ByteSlice方法返回一个ByteView的副本，防止被篡改
 func (v ByteView) ByteSlice() []byte {
 	return cloneBytes(v.b)
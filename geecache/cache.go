@@ -2,45 +2,255 @@ package geecache
 
 import (
 	"Learning_Code/geecache/lru"
+	"Learning_Code/geecache/tinylfu"
 	"sync"
+	"time"
 )
 
-//为lru.Cache增加并发特性
+//为淘汰策略（默认为lru.Cache）增加并发特性
+//cache按key的哈希值划分为多个分片，每个分片有独立的锁和容量，降低并发访问时的锁竞争
 
 type cache struct {
+	cacheBytes int64
+	newPolicy  NewPolicyFunc // 淘汰策略的构造函数，为nil时使用LRU
+	nShards    int           // 分片数量，小于等于0时只有一个分片
+	samples    int           // 大于0时启用TinyLFU准入策略，为所有分片的采样总数
+	shards     []*shard
+	initOnce   sync.Once // 保证分片只初始化一次
+	janitor    sync.Once // 保证后台清理协程只启动一次
+	evictions  AtomicInt // 所有分片淘汰的条目数量
+}
+
+//缓存中的一个条目，过期时间由ByteView携带
+type cacheEntry struct {
+	key   string
+	value ByteView
+}
+
+//单个分片，即原先由一把锁保护的整个缓存
+type shard struct {
 	mu         sync.Mutex
-	lru        *lru.Cache
+	lru        EvictionPolicy
 	cacheBytes int64
+	newPolicy  NewPolicyFunc
+	admission  *tinylfu.TinyLFU // 准入策略，为nil时不启用
+	evictions  *AtomicInt       // 指向cache的淘汰计数
+	removing   bool             // 正在主动删除条目，此时的回调不计入淘汰次数
+}
+
+//能够给出淘汰对象的淘汰策略才支持准入策略，内置的LRU、LFU、ARC、TwoQ都实现了该接口
+type victimer interface {
+	Victim(key string, value lru.Value) (victim string, ok bool)
+}
+
+//延迟初始化所有分片，每个分片平分总容量
+func (c *cache) init() {
+	c.initOnce.Do(func() {
+		n := c.nShards
+		if n <= 0 {
+			n = 1
+		}
+		//容量小于分片数时每个分片的容量向下取整为0，即不限制容量，因此减少分片数使每个分片至少有1字节
+		if c.cacheBytes > 0 && int64(n) > c.cacheBytes {
+			n = int(c.cacheBytes)
+		}
+		if c.newPolicy == nil {
+			c.newPolicy = LRU
+		}
+		c.shards = make([]*shard, n)
+		for i := range c.shards {
+			c.shards[i] = &shard{cacheBytes: c.cacheBytes / int64(n), newPolicy: c.newPolicy, evictions: &c.evictions}
+			if c.samples > 0 {
+				c.shards[i].admission = tinylfu.New(c.samples / n)
+			}
+		}
+	})
+}
+
+//根据key的FNV-1a哈希值选择分片
+func (c *cache) getShard(key string) *shard {
+	c.init()
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return c.shards[h%uint32(len(c.shards))]
 }
 
 func (c *cache) add(key string, value ByteView) {
+	c.getShard(key).add(key, value)
+}
+
+//与add相同，但启用了准入策略时，新条目只有比淘汰对象访问更频繁才会被加入
+func (c *cache) admit(key string, value ByteView) {
+	c.getShard(key).admit(key, value)
+}
+
+func (c *cache) get(key string) (value ByteView, ok bool) {
+	return c.getShard(key).get(key)
+}
+
+func (c *cache) remove(key string) {
+	c.getShard(key).remove(key)
+}
+
+//按分片依次收集所有未过期的条目，每个分片内按淘汰顺序排列
+func (c *cache) entries() []cacheEntry {
+	c.init()
+	var list []cacheEntry
+	for _, s := range c.shards {
+		list = s.entries(list)
+	}
+	return list
+}
+
+//汇总所有分片的统计数据
+func (c *cache) stats() CacheStats {
+	c.init()
+	st := CacheStats{Evictions: c.evictions.Get()}
+	for _, s := range c.shards {
+		items, bytes := s.size()
+		st.Items += items
+		st.Bytes += bytes
+	}
+	return st
+}
+
+//清理所有分片中已过期的条目
+func (c *cache) removeExpired() {
+	c.init()
+	for _, s := range c.shards {
+		s.removeExpired()
+	}
+}
+
+//启动后台清理协程，每隔interval清理一次过期条目，stop返回true时协程退出，多次调用只会启动一个协程
+func (c *cache) startJanitor(interval time.Duration, stop func() bool) {
+	c.janitor.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if stop() {
+					return
+				}
+				c.removeExpired()
+			}
+		}()
+	})
+}
+
+//创建淘汰策略，并在条目被淘汰时累加淘汰计数，调用方需持有锁
+func (s *shard) lazyInit() {
+	if s.lru != nil {
+		return
+	}
+	s.lru = s.newPolicy(s.cacheBytes, func(key string, value lru.Value) {
+		if !s.removing && s.evictions != nil {
+			s.evictions.Add(1)
+		}
+	})
+}
+
+func (s *shard) add(key string, value ByteView) {
 	//加锁，延迟解锁
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	//延迟初始化：该对象的创建将会延迟至第一次使用该对象时。主要用于提高性能，并减少程序内存要求。
-	if c.lru == nil {
-		c.lru = lru.New(c.cacheBytes, nil)
-	}
+	s.lazyInit()
 
-	//调用Add添加
-	c.lru.Add(key, value)
+	//调用AddWithExpire添加，过期时间由ByteView携带
+	s.lru.AddWithExpire(key, value, value.Expire())
 }
 
-func (c *cache) get(key string) (value ByteView, ok bool) {
+func (s *shard) admit(key string, value ByteView) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lazyInit()
+
+	//加入新条目需要淘汰其他条目时，由准入策略决定是否用它替换淘汰对象
+	if v, ok := s.lru.(victimer); ok && s.admission != nil {
+		if victim, ok := v.Victim(key, value); ok && !s.admission.Admit(key, victim) {
+			return
+		}
+	}
+	s.lru.AddWithExpire(key, value, value.Expire())
+}
+
+func (s *shard) get(key string) (value ByteView, ok bool) {
 	//加锁，延迟解锁
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	//无论是否命中，都记录一次访问频率
+	if s.admission != nil {
+		s.admission.Increment(key)
+	}
 
 	//空指针处理
-	if c.lru == nil {
+	if s.lru == nil {
 		return
 	}
 
 	//不为空则调用Get查找
-	if v, ok := c.lru.Get(key); ok {
+	if v, ok := s.lru.Get(key); ok {
 		return v.(ByteView), ok
 	}
 
 	return
 }
+
+func (s *shard) remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lru == nil {
+		return
+	}
+	s.removing = true
+	s.lru.Remove(key)
+	s.removing = false
+}
+
+//将分片中未过期的条目追加到list中
+func (s *shard) entries(list []cacheEntry) []cacheEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lru == nil {
+		return list
+	}
+	s.lru.Range(func(key string, value lru.Value, expire time.Time) bool {
+		list = append(list, cacheEntry{key: key, value: value.(ByteView)})
+		return true
+	})
+	return list
+}
+
+//返回分片的条目数量和已用容量
+func (s *shard) size() (items int64, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lru == nil {
+		return
+	}
+	return int64(s.lru.Len()), s.lru.Bytes()
+}
+
+//清理分片中已过期的条目
+func (s *shard) removeExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lru == nil {
+		return
+	}
+	s.lru.RemoveExpired()
+}
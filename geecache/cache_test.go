@@ -0,0 +1,88 @@
+package geecache
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//测试分片后每个分片独立淘汰，且总容量不超过cacheBytes
+func TestCacheShards(t *testing.T) {
+	c := &cache{cacheBytes: 1 << 10, nShards: 4}
+	for i := 0; i < 1000; i++ {
+		c.add("key"+strconv.Itoa(i), ByteView{b: []byte("value")})
+	}
+
+	total := 0
+	for _, s := range c.shards {
+		if s.lru == nil || s.lru.Len() == 0 {
+			t.Fatalf("keys should be spread over all shards")
+		}
+		total += s.lru.Len() * len("key000value")
+	}
+	if total > 1<<10 {
+		t.Fatalf("cache holds %d bytes, more than cacheBytes", total)
+	}
+	if _, ok := c.get("key999"); !ok {
+		t.Fatalf("cache hit key999 failed")
+	}
+}
+
+//测试容量小于分片数时仍然限制总容量
+func TestCacheTinyShards(t *testing.T) {
+	c := &cache{cacheBytes: 3, nShards: 8}
+	for i := 0; i < 26; i++ {
+		c.add(string(rune('a'+i)), ByteView{})
+	}
+	if st := c.stats(); st.Bytes > 3 || len(c.shards) != 3 {
+		t.Fatalf("cache holds %d bytes in %d shards, more than cacheBytes", st.Bytes, len(c.shards))
+	}
+}
+
+//并发读写下不同分片数的吞吐量，使用 go test -bench Cache -cpu 1,2,4,8 观察随GOMAXPROCS的变化
+func BenchmarkCache(b *testing.B) {
+	const nKeys = 1 << 12
+	keys := make([]string, nKeys)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	value := ByteView{b: make([]byte, 64)}
+
+	for _, n := range []int{1, 4, 16, 64} {
+		b.Run("shards="+strconv.Itoa(n), func(b *testing.B) {
+			c := &cache{cacheBytes: 1 << 20, nShards: n}
+			for _, k := range keys {
+				c.add(k, value)
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					k := keys[i%nKeys]
+					//读写比为9:1
+					if i%10 == 0 {
+						c.add(k, value)
+					} else {
+						c.get(k)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+//测试stop返回true后清理协程退出
+func TestCacheJanitorStop(t *testing.T) {
+	c := &cache{cacheBytes: 1 << 10}
+	var checks int32
+	c.startJanitor(time.Millisecond, func() bool {
+		atomic.AddInt32(&checks, 1)
+		return true
+	})
+	time.Sleep(20 * time.Millisecond)
+	if n := atomic.LoadInt32(&checks); n != 1 {
+		t.Fatalf("janitor should exit after stop returns true, checked %d times", n)
+	}
+}
@@ -0,0 +1,114 @@
+package consistenthash
+
+import (
+	"math"
+	"sync"
+)
+
+// boundedLoad 记录每个节点正在处理的请求数量，实现 Google 的“有界负载一致性哈希”：
+// 每个节点的负载上限为 ceil((总负载+1) * (1+epsilon) * 权重 / 总权重)，
+// 查找时从key在哈希环上的位置顺时针走，跳过已达到上限的节点，避免少数热点key压垮一个节点
+type boundedLoad struct {
+	epsilon float64
+
+	mu    sync.Mutex // 负载由请求结束时并发报告，单独加锁
+	loads map[string]int64
+	total int64
+}
+
+// NewBounded 创建启用有界负载模式的Map，epsilon越小负载越均衡，但key在节点间移动得越频繁。
+// 调用方需要在请求开始和结束时调用 Inc 和 Done 报告节点的负载
+func NewBounded(replicas int, epsilon float64, fn Hash) *Map {
+	m := New(replicas, fn)
+	m.bounded = &boundedLoad{epsilon: epsilon, loads: make(map[string]int64)}
+	return m
+}
+
+// Inc 记录节点开始处理一个请求，未启用有界负载模式时不做任何事
+func (m *Map) Inc(node string) {
+	if m.bounded == nil {
+		return
+	}
+	b := m.bounded
+	b.mu.Lock()
+	b.loads[node]++
+	b.total++
+	b.mu.Unlock()
+}
+
+// Done 记录节点处理完一个请求
+func (m *Map) Done(node string) {
+	if m.bounded == nil {
+		return
+	}
+	b := m.bounded
+	b.mu.Lock()
+	if b.loads[node] > 0 {
+		b.loads[node]--
+		b.total--
+	}
+	if b.loads[node] == 0 {
+		delete(b.loads, node)
+	}
+	b.mu.Unlock()
+}
+
+// Load 返回节点正在处理的请求数量
+func (m *Map) Load(node string) int64 {
+	if m.bounded == nil {
+		return 0
+	}
+	m.bounded.mu.Lock()
+	defer m.bounded.mu.Unlock()
+	return m.bounded.loads[node]
+}
+
+// 节点能否再接受一个请求，调用方需持有b.mu
+func (m *Map) underCapacity(node string, totalWeight int) bool {
+	b := m.bounded
+	capacity := math.Ceil(float64(b.total+1) * (1 + b.epsilon) * float64(m.weights[node]) / float64(totalWeight))
+	return float64(b.loads[node]+1) <= capacity
+}
+
+// 所有节点的权重之和
+func (m *Map) totalWeight() int {
+	total := 0
+	for _, w := range m.weights {
+		total += w
+	}
+	return total
+}
+
+// 从哈希环上的第idx个虚拟节点开始顺时针查找第一个未达到负载上限的节点
+func (m *Map) getBounded(idx int) string {
+	totalWeight := m.totalWeight()
+	m.bounded.mu.Lock()
+	defer m.bounded.mu.Unlock()
+
+	first := m.hashMap[m.keys[idx%len(m.keys)]]
+	for i := 0; i < len(m.keys); i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if m.underCapacity(node, totalWeight) {
+			return node
+		}
+	}
+	return first
+}
+
+// 保持原有顺序，将未达到负载上限的节点排在前面
+func (m *Map) preferUnderloaded(nodes []string) []string {
+	totalWeight := m.totalWeight()
+	m.bounded.mu.Lock()
+	defer m.bounded.mu.Unlock()
+
+	sorted := make([]string, 0, len(nodes))
+	var full []string
+	for _, node := range nodes {
+		if m.underCapacity(node, totalWeight) {
+			sorted = append(sorted, node)
+		} else {
+			full = append(full, node)
+		}
+	}
+	return append(sorted, full...)
+}
@@ -11,23 +11,39 @@ type Hash func(data []byte) uint32
 
 //	Map包含了所有散列的键
 type Map struct {
-	hash     Hash           // 哈希函数
-	replicas int            // 虚拟节点倍数
-	keys     []int          // Sorted  哈希环
-	hashMap  map[int]string // 虚拟节点和真实节点的映射表
+	hash     Hash64            // 哈希函数，32位的哈希值直接扩展为64位
+	space    float64           // 哈希值的取值范围，用于计算节点在环上所占的比例
+	replicas int               // 虚拟节点倍数
+	keys     []uint64          // Sorted  哈希环
+	hashMap  map[uint64]string // 虚拟节点和真实节点的映射表
+	probes   map[uint64]probe  // 每个位置上的虚拟节点，哈希冲突时用于决定谁让出位置
+	weights  map[string]int    // 真实节点的权重，虚拟节点数量为 replicas * 权重
+	bounded  *boundedLoad      // 有界负载模式的负载统计，为nil时不启用
 }
 
+// 哈希环上一个位置对应的虚拟节点：名称以及第几次探测得到这个位置
+type probe struct {
+	name    string
+	attempt int
+}
+
+// 一个虚拟节点最多探测的次数，超过后放弃该虚拟节点，避免哈希函数退化时无限循环
+const maxProbes = 16
+
 // 实例化Map，采用依赖注入，允许自定义虚拟节点倍数，允许自定义Hash函数，默认为crc32.ChecksumIEEE算法
 func New(replicas int, fn Hash) *Map {
 	m := &Map{
 		replicas: replicas,
-		hash:     fn,
-		hashMap:  make(map[int]string),
+		space:    1 << 32,
+		hashMap:  make(map[uint64]string),
+		probes:   make(map[uint64]probe),
+		weights:  make(map[string]int),
 	}
 
-	if m.hash == nil {
-		m.hash = crc32.ChecksumIEEE
+	if fn == nil {
+		fn = crc32.ChecksumIEEE
 	}
+	m.hash = func(data []byte) uint64 { return uint64(fn(data)) }
 
 	return m
 }
@@ -35,20 +51,91 @@ func New(replicas int, fn Hash) *Map {
 // 添加节点/机器的 Add() 方法
 // 传入 0 或 多个 真实节点的名称以添加节点
 func (m *Map) Add(keys ...string) {
-	// 遍历每一个添加的真是节点
+	// 遍历每一个添加的真是节点，权重均为1
 	for _, key := range keys {
-		// 每个真实节点都创建 m.replicas 个虚拟节点
-		for i := 0; i < m.replicas; i++ {
-			// 虚拟节点的名称为 i + key
-			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
-			// 将虚拟节点名称哈希后加到环上
+		m.add(key, 1)
+	}
+	// 将环上的哈希值排序
+	m.sort()
+}
+
+// AddWeighted 添加一个权重为weight的节点，虚拟节点数量为 replicas * weight，
+// 负责的key的比例与权重成正比，适用于容量不同的机器。weight小于等于0时不添加
+func (m *Map) AddWeighted(key string, weight int) {
+	if weight <= 0 {
+		return
+	}
+	m.add(key, weight)
+	m.sort()
+}
+
+// 添加节点的虚拟节点，调用方负责排序
+func (m *Map) add(key string, weight int) {
+	// 每个真实节点都创建 m.replicas * weight 个虚拟节点，
+	// 已有的虚拟节点从 replicas * 原权重 开始编号，增加权重不改变原有虚拟节点的位置
+	for i := m.replicas * m.weights[key]; i < m.replicas*(m.weights[key]+weight); i++ {
+		// 虚拟节点的名称为 i + key
+		m.place(key, strconv.Itoa(i)+key, 0)
+	}
+	m.weights[key] += weight
+}
+
+// 将虚拟节点从第attempt次探测开始放到环上。第0次探测的位置为名称的哈希值，之后为 名称#attempt 的哈希值。
+// 位置已被其他虚拟节点占用时，真实节点名称较小的一方（相同时比较虚拟节点名称）保留位置，另一方继续探测，
+// 因此环的结果与节点加入的顺序无关。探测 maxProbes 次仍失败的虚拟节点被放弃
+func (m *Map) place(key, name string, attempt int) {
+	for ; attempt < maxProbes; attempt++ {
+		data := name
+		if attempt > 0 {
+			data += "#" + strconv.Itoa(attempt)
+		}
+		hash := m.hash([]byte(data))
+		owner, ok := m.hashMap[hash]
+		if !ok {
+			// 将虚拟节点名称哈希后加到环上，在hashMap上增加虚拟节点与真实节点的映射
 			m.keys = append(m.keys, hash)
-			// 在hashMap上增加虚拟节点与真实节点的映射
 			m.hashMap[hash] = key
+			m.probes[hash] = probe{name: name, attempt: attempt}
+			return
+		}
+		old := m.probes[hash]
+		if key < owner || key == owner && name < old.name {
+			// 抢占位置，原来的虚拟节点继续探测
+			m.hashMap[hash] = key
+			m.probes[hash] = probe{name: name, attempt: attempt}
+			m.place(owner, old.name, old.attempt+1)
+			return
 		}
 	}
-	// 将环上的哈希值排序
-	sort.Ints(m.keys)
+}
+
+func (m *Map) sort() {
+	sort.Slice(m.keys, func(i, j int) bool { return m.keys[i] < m.keys[j] })
+}
+
+// Remove 从哈希环上删除节点及其所有虚拟节点，其他节点负责的key不受影响。
+// 被删除节点抢占过位置的虚拟节点需要回到原来的位置，因此按剩余节点重建哈希环，结果与从未加入过被删除节点相同
+func (m *Map) Remove(keys ...string) {
+	removed := false
+	for _, key := range keys {
+		if _, ok := m.weights[key]; ok {
+			delete(m.weights, key)
+			removed = true
+		}
+	}
+	if !removed {
+		return
+	}
+
+	weights := m.weights
+	m.keys = m.keys[:0]
+	m.hashMap = make(map[uint64]string, len(m.hashMap))
+	m.probes = make(map[uint64]probe, len(m.probes))
+	m.weights = make(map[string]int, len(weights))
+	for key, weight := range weights {
+		m.add(key, weight)
+	}
+	m.sort()
 }
 
 // 实现选择节点的 Get()方法
@@ -58,13 +145,136 @@ func (m *Map) Get(key string) string {
 		return ""
 	}
 
+	idx := m.search(key)
+	// 有界负载模式下跳过负载过高的节点
+	if m.bounded != nil {
+		return m.getBounded(idx)
+	}
+	// 环装结构需要取余，通过hashMap返回真实节点
+	return m.hashMap[m.keys[idx%len(m.keys)]]
+}
+
+// 返回key在哈希环上对应的第一个虚拟节点的下标，可能等于len(m.keys)，调用方需要取余
+func (m *Map) search(key string) int {
 	// 将key哈希
-	hash := int(m.hash([]byte(key)))
+	hash := m.hash([]byte(key))
 	// 二分查找对应的虚拟节点
 	// 第一个参数是范围，第二个参数是自定义函数，规则是找到第一个表达式为true的index
-	idx := sort.Search(len(m.keys), func(i int) bool {
+	return sort.Search(len(m.keys), func(i int) bool {
 		return m.keys[i] >= hash
 	})
-	// 环装结构需要取余，通过hashMap返回真实节点
-	return m.hashMap[m.keys[idx%len(m.keys)]]
+}
+
+// Owner 返回哈希环上key所属的节点，与 Get 不同，不考虑有界负载模式下节点的负载
+func (m *Map) Owner(key string) string {
+	if len(m.keys) == 0 {
+		return ""
+	}
+	return m.hashMap[m.keys[m.search(key)%len(m.keys)]]
+}
+
+// OwnersN 返回哈希环上key依次经过的最多n个不同节点，与 GetN 不同，不考虑节点的负载
+func (m *Map) OwnersN(key string, n int) []string {
+	if len(m.keys) == 0 || n <= 0 {
+		return nil
+	}
+	return m.walk(m.search(key), n)
+}
+
+// 从第idx个虚拟节点开始沿哈希环走，跳过属于已选节点的虚拟节点，返回最多n个不同的节点，最多走一圈
+func (m *Map) walk(idx int, n int) []string {
+	nodes := make([]string, 0, n)
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// GetN 从key在哈希环上的位置开始顺时针查找，返回最多n个不同的真实节点，第一个与 Get 的结果相同。
+// 主节点不可用时，可以依次尝试后面的节点
+func (m *Map) GetN(key string, n int) []string {
+	if len(m.keys) == 0 || n <= 0 {
+		return nil
+	}
+
+	// 有界负载模式下需要所有节点的顺序，再把负载过高的节点排到后面
+	want := n
+	if m.bounded != nil {
+		want = len(m.weights)
+	}
+	nodes := m.walk(m.search(key), want)
+	if m.bounded != nil {
+		nodes = m.preferUnderloaded(nodes)
+		if len(nodes) > n {
+			nodes = nodes[:n]
+		}
+	}
+	return nodes
+}
+
+func contains(nodes []string, node string) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回哈希环上虚拟节点的数量
+func (m *Map) Len() int {
+	return len(m.keys)
+}
+
+// NodeShare 描述一个真实节点在哈希环上的情况
+type NodeShare struct {
+	Node         string
+	Weight       int
+	VirtualNodes int     // 实际在环上的虚拟节点数量，少于 replicas * Weight 说明有虚拟节点探测失败被放弃
+	Moved        int     // 因哈希冲突没有放在第一次探测位置上的虚拟节点数量
+	Share        float64 // 负责的哈希空间的比例，理想值为 Weight / 总权重
+}
+
+// Describer 由可以报告节点分布情况的Selector实现，用于诊断负载不均衡
+type Describer interface {
+	Describe() []NodeShare
+}
+
+var _ Describer = (*Map)(nil)
+
+// Describe 返回每个节点在哈希环上所占的比例，按节点名称排序。
+// 每个虚拟节点负责从前一个虚拟节点（不含）到自己（含）之间的哈希值，不考虑有界负载模式的跳过
+func (m *Map) Describe() []NodeShare {
+	shares := make(map[string]*NodeShare, len(m.weights))
+	nodes := make([]string, 0, len(m.weights))
+	for node, weight := range m.weights {
+		shares[node] = &NodeShare{Node: node, Weight: weight}
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for i, hash := range m.keys {
+		var arc float64
+		if i == 0 {
+			// 第一个虚拟节点还负责环的末尾
+			arc = float64(hash) + (m.space - float64(m.keys[len(m.keys)-1]))
+		} else {
+			arc = float64(hash - m.keys[i-1])
+		}
+		s := shares[m.hashMap[hash]]
+		s.VirtualNodes++
+		if m.probes[hash].attempt > 0 {
+			s.Moved++
+		}
+		s.Share += arc / m.space
+	}
+
+	result := make([]NodeShare, len(nodes))
+	for i, node := range nodes {
+		result[i] = *shares[node]
+	}
+	return result
 }
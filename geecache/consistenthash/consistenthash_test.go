@@ -1,6 +1,9 @@
 package consistenthash
 
 import (
+	"hash/fnv"
+	"math"
+	"reflect"
 	"strconv"
 	"testing"
 )
@@ -43,3 +46,212 @@ func TestHashing(t *testing.T) {
 	}
 
 }
+
+func TestGetN(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+	// 虚拟节点依次为 02 04 06 12 14 16 22 24 26
+	hash.Add("6", "4", "2")
+
+	testCases := map[string][]string{
+		"11": {"2", "4"},
+		"23": {"4", "6"},
+		"27": {"2", "4"},
+	}
+	for k, v := range testCases {
+		if got := hash.GetN(k, 2); !reflect.DeepEqual(got, v) {
+			t.Errorf("Asking for %s, should have yielded %v, got %v", k, v, got)
+		}
+	}
+
+	// n超过真实节点数量时返回所有节点
+	if got := hash.GetN("5", 5); !reflect.DeepEqual(got, []string{"6", "2", "4"}) {
+		t.Errorf("GetN should return every node once, got %v", got)
+	}
+}
+
+// 删除节点后只有该节点负责的key移动到其他节点，重新加入后恢复原状
+func TestRemove(t *testing.T) {
+	hash := New(50, nil)
+	hash.Add("a", "b", "c", "d")
+
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		before[key] = hash.Get(key)
+	}
+
+	hash.Remove("c")
+	if hash.Len() != 150 {
+		t.Fatalf("expect 150 virtual nodes after remove, got %d", hash.Len())
+	}
+	for key, node := range before {
+		got := hash.Get(key)
+		if node == "c" && got == "c" {
+			t.Fatalf("key %s should move away from the removed node", key)
+		}
+		if node != "c" && got != node {
+			t.Fatalf("key %s moved from %s to %s, but only c was removed", key, node, got)
+		}
+	}
+
+	hash.Add("c")
+	for key, node := range before {
+		if got := hash.Get(key); got != node {
+			t.Fatalf("key %s should return to %s after re-adding, got %s", key, node, got)
+		}
+	}
+}
+
+// 每个节点负责的key的比例应与权重成正比
+func TestAddWeighted(t *testing.T) {
+	hash := New(100, nil)
+	weights := map[string]int{"small": 1, "medium": 2, "large": 4}
+	total := 0
+	for node, weight := range weights {
+		hash.AddWeighted(node, weight)
+		total += weight
+	}
+	if hash.Len() != 100*total {
+		t.Fatalf("expect %d virtual nodes, got %d", 100*total, hash.Len())
+	}
+
+	const n = 100000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[hash.Get("key"+strconv.Itoa(i))]++
+	}
+	for node, weight := range weights {
+		expect := float64(n) * float64(weight) / float64(total)
+		if got := float64(counts[node]); got < expect*0.8 || got > expect*1.2 {
+			t.Errorf("node %s with weight %d got %d keys, expect about %.0f", node, weight, counts[node], expect)
+		}
+	}
+
+	// 删除带权重的节点时删除它的所有虚拟节点
+	hash.Remove("large")
+	if hash.Len() != 100*3 {
+		t.Fatalf("expect 300 virtual nodes after remove, got %d", hash.Len())
+	}
+}
+
+// 有界负载模式下跳过负载达到上限的节点
+func TestBounded(t *testing.T) {
+	hash := NewBounded(3, 0, func(key []byte) uint32 {
+		i, _ := strconv.Atoi(string(key))
+		return uint32(i)
+	})
+	// 虚拟节点依次为 02 04 06 12 14 16 22 24 26
+	hash.Add("6", "4", "2")
+
+	if got := hash.Get("11"); got != "2" {
+		t.Fatalf("Asking for 11, should have yielded 2, got %s", got)
+	}
+	// 总负载为1时每个节点的上限为 ceil(2/3) = 1，节点2已满
+	hash.Inc("2")
+	if got := hash.Get("11"); got != "4" {
+		t.Fatalf("11 should skip the loaded node 2, got %s", got)
+	}
+	if got := hash.GetN("11", 3); !reflect.DeepEqual(got, []string{"4", "6", "2"}) {
+		t.Fatalf("GetN should put the loaded node last, got %v", got)
+	}
+	// 归属节点不随负载变化
+	if got := hash.Owner("11"); got != "2" {
+		t.Fatalf("owner of 11 should stay 2 under load, got %s", got)
+	}
+	if got := hash.OwnersN("11", 3); !reflect.DeepEqual(got, []string{"2", "4", "6"}) {
+		t.Fatalf("OwnersN should follow the ring, got %v", got)
+	}
+	hash.Done("2")
+	if got := hash.Get("11"); got != "2" || hash.Load("2") != 0 {
+		t.Fatalf("11 should return to 2 after Done, got %s", got)
+	}
+
+	// 所有请求都落在同一个key上时，负载仍然不超过上限
+	hash = NewBounded(50, 0.25, nil)
+	hash.Add("a", "b", "c", "d")
+	for total := 0; total < 100; total++ {
+		hash.Inc(hash.Get("hot"))
+	}
+	for _, node := range []string{"a", "b", "c", "d"} {
+		if load := hash.Load(node); load > 32 {
+			t.Fatalf("node %s has load %d, above the bound", node, load)
+		}
+	}
+}
+
+// 测试哈希冲突时名称较小的节点保留位置，另一方继续探测，结果与加入顺序无关
+func TestCollision(t *testing.T) {
+	// 虚拟节点 0a 和 0b 冲突，探测得到的 0b#1 放在位置 1
+	hash := func(key []byte) uint32 {
+		switch string(key) {
+		case "0a", "0b":
+			return 10
+		case "0b#1":
+			return 1
+		}
+		return 100
+	}
+
+	for _, order := range [][]string{{"a", "b"}, {"b", "a"}} {
+		m := New(1, hash)
+		m.Add(order...)
+		if m.Len() != 2 {
+			t.Fatalf("add %v: expect 2 virtual nodes, got %d", order, m.Len())
+		}
+		if m.Get("0a") != "a" || m.Get("0b#1") != "b" {
+			t.Fatalf("add %v: a should keep the slot and b should move, got %s and %s", order, m.Get("0a"), m.Get("0b#1"))
+		}
+	}
+
+	// 删除a后b回到原来的位置
+	m := New(1, hash)
+	m.Add("a", "b")
+	m.Remove("a")
+	if shares := m.Describe(); len(shares) != 1 || shares[0].Moved != 0 || m.Get("0a") != "b" {
+		t.Fatalf("b should move back after removing a, got %+v", shares)
+	}
+
+	// 所有探测都冲突时放弃虚拟节点，而不是无限循环
+	m = New(1, func(key []byte) uint32 { return 7 })
+	m.Add("a", "b", "c")
+	if m.Len() != 1 || m.Get("x") != "a" {
+		t.Fatalf("expect only a on the ring, got %d virtual nodes", m.Len())
+	}
+}
+
+// 测试64位哈希环的节点分布，以及 Describe 报告的比例
+func TestHash64(t *testing.T) {
+	if got, want := FNV64a([]byte("geecache")), fnv64a("geecache"); got != want {
+		t.Fatalf("FNV64a = %x, want %x", got, want)
+	}
+
+	m := NewHash64(100, nil)
+	m.AddWeighted("small", 1)
+	m.AddWeighted("large", 3)
+	shares := m.Describe()
+	if len(shares) != 2 || shares[0].Node != "large" || shares[0].VirtualNodes != 300 || shares[1].VirtualNodes != 100 {
+		t.Fatalf("unexpected shares %+v", shares)
+	}
+	if total := shares[0].Share + shares[1].Share; math.Abs(total-1) > 1e-9 {
+		t.Fatalf("shares should add up to 1, got %f", total)
+	}
+	if got := shares[0].Share; got < 0.7 || got > 0.8 {
+		t.Fatalf("large node with weight 3 should own about 0.75 of the ring, got %f", got)
+	}
+
+	// 只有一个虚拟节点时负责整个环
+	m = New(1, nil)
+	m.Add("a")
+	if shares := m.Describe(); shares[0].Share != 1 {
+		t.Fatalf("a single node should own the whole ring, got %f", shares[0].Share)
+	}
+}
+
+func fnv64a(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
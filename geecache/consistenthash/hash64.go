@@ -0,0 +1,31 @@
+package consistenthash
+
+// Hash64 将[]byte映射为64位的哈希值，32位的哈希环上虚拟节点较多时容易发生冲突
+type Hash64 func(data []byte) uint64
+
+const (
+	fnv64Offset = 14695981039346656037
+	fnv64Prime  = 1099511628211
+)
+
+// FNV64a 是64位的 FNV-1a 哈希，结果与 hash/fnv.New64a 相同，但不需要分配内存
+func FNV64a(data []byte) uint64 {
+	h := uint64(fnv64Offset)
+	for _, c := range data {
+		h ^= uint64(c)
+		h *= fnv64Prime
+	}
+	return h
+}
+
+// NewHash64 创建使用64位哈希值的Map，fn为nil时使用 FNV64a。
+// FNV-1a 对只有末尾几个字节不同的输入高位变化不充分，结果再经过一次 mix64 使虚拟节点在环上分布均匀
+func NewHash64(replicas int, fn Hash64) *Map {
+	if fn == nil {
+		fn = func(data []byte) uint64 { return mix64(FNV64a(data)) }
+	}
+	m := New(replicas, nil)
+	m.hash = fn
+	m.space = 1 << 64
+	return m
+}
@@ -0,0 +1,110 @@
+package consistenthash
+
+import (
+	"hash/crc32"
+	"sort"
+)
+
+// Jump 实现跳跃一致性哈希（Lamping & Veach）：不需要额外内存，计算量为 O(ln n)，分布非常均匀。
+// 节点按名称排序后编号为桶，各节点上的结果与加入顺序无关。只有名称排在最后的节点增删时key的移动最少；
+// 增删中间的节点会使后面的桶重新编号，移动的key较多，因此适合按名称递增扩容的场景。权重为w的节点占用w个桶
+type Jump struct {
+	hash    Hash
+	buckets []string       // 桶到节点的映射，按节点名称排列
+	weights map[string]int // 节点的权重
+}
+
+// NewJump 创建Jump，fn为nil时使用crc32.ChecksumIEEE
+func NewJump(fn Hash) *Jump {
+	j := &Jump{hash: fn, weights: make(map[string]int)}
+	if j.hash == nil {
+		j.hash = crc32.ChecksumIEEE
+	}
+	return j
+}
+
+// Add 添加权重为1的节点
+func (j *Jump) Add(nodes ...string) {
+	for _, node := range nodes {
+		j.AddWeighted(node, 1)
+	}
+}
+
+// AddWeighted 为node增加weight个桶，weight小于等于0时不添加
+func (j *Jump) AddWeighted(node string, weight int) {
+	if weight <= 0 {
+		return
+	}
+	j.weights[node] += weight
+	j.rebuild()
+}
+
+// Remove 删除节点的所有桶
+func (j *Jump) Remove(nodes ...string) {
+	for _, node := range nodes {
+		delete(j.weights, node)
+	}
+	j.rebuild()
+}
+
+// 按节点名称重新编号所有的桶，同一个节点的桶相邻
+func (j *Jump) rebuild() {
+	nodes := make([]string, 0, len(j.weights))
+	for node := range j.weights {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	j.buckets = j.buckets[:0]
+	for _, node := range nodes {
+		for i := 0; i < j.weights[node]; i++ {
+			j.buckets = append(j.buckets, node)
+		}
+	}
+}
+
+// 跳跃一致性哈希算法，返回key在n个桶中的编号
+func jump(key uint64, n int) int {
+	var b, i int64 = -1, 0
+	for i < int64(n) {
+		b = i
+		key = key*2862933555777941757 + 1
+		i = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// Get 返回负责key的节点
+func (j *Jump) Get(key string) string {
+	if len(j.buckets) == 0 {
+		return ""
+	}
+	return j.buckets[jump(uint64(mix32(j.hash([]byte(key)))), len(j.buckets))]
+}
+
+// GetN 依次在剩余节点的桶中选择，返回最多n个不同的节点
+func (j *Jump) GetN(key string, n int) []string {
+	if len(j.buckets) == 0 || n <= 0 {
+		return nil
+	}
+	h := uint64(mix32(j.hash([]byte(key))))
+	buckets := j.buckets
+	var nodes []string
+	for len(buckets) > 0 && len(nodes) < n {
+		node := buckets[jump(h, len(buckets))]
+		nodes = append(nodes, node)
+		// 去掉已选节点的桶，在剩下的桶中继续选择
+		rest := make([]string, 0, len(buckets))
+		for _, b := range buckets {
+			if b != node {
+				rest = append(rest, b)
+			}
+		}
+		buckets = rest
+	}
+	return nodes
+}
+
+// Len 返回桶的数量
+func (j *Jump) Len() int {
+	return len(j.buckets)
+}
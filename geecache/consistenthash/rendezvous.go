@@ -0,0 +1,133 @@
+package consistenthash
+
+import (
+	"hash/crc32"
+	"math"
+	"sort"
+)
+
+// Rendezvous 实现最高随机权重（HRW）哈希：对每个节点计算 hash(节点, key) 的得分，得分最高的节点负责key。
+// 不需要虚拟节点就能均匀分布，删除节点时只有该节点的key会移动，代价是每次选择需要遍历所有节点
+type Rendezvous struct {
+	hash     Hash
+	nodes    []rendezvousNode // 按名称排序，保证结果与添加顺序无关
+	weighted bool             // 是否有权重不为1的节点
+}
+
+type rendezvousNode struct {
+	name   string
+	seed   uint32 // 节点名称的哈希值，与key的哈希值组合后计算得分
+	weight int
+}
+
+// NewRendezvous 创建Rendezvous，fn为nil时使用crc32.ChecksumIEEE
+func NewRendezvous(fn Hash) *Rendezvous {
+	r := &Rendezvous{hash: fn}
+	if r.hash == nil {
+		r.hash = crc32.ChecksumIEEE
+	}
+	return r
+}
+
+// 返回节点在r.nodes中的位置，ok表示节点是否存在
+func (r *Rendezvous) search(node string) (i int, ok bool) {
+	i = sort.Search(len(r.nodes), func(i int) bool { return r.nodes[i].name >= node })
+	return i, i < len(r.nodes) && r.nodes[i].name == node
+}
+
+// Add 添加权重为1的节点
+func (r *Rendezvous) Add(nodes ...string) {
+	for _, node := range nodes {
+		r.AddWeighted(node, 1)
+	}
+}
+
+// AddWeighted 添加一个权重为weight的节点，weight小于等于0时不添加
+func (r *Rendezvous) AddWeighted(node string, weight int) {
+	if weight <= 0 {
+		return
+	}
+	defer r.updateWeighted()
+	i, ok := r.search(node)
+	if ok {
+		r.nodes[i].weight += weight
+		return
+	}
+	r.nodes = append(r.nodes, rendezvousNode{})
+	copy(r.nodes[i+1:], r.nodes[i:])
+	r.nodes[i] = rendezvousNode{name: node, seed: r.hash([]byte(node)), weight: weight}
+}
+
+// Remove 删除节点，只有这些节点负责的key会移动
+func (r *Rendezvous) Remove(nodes ...string) {
+	for _, node := range nodes {
+		if i, ok := r.search(node); ok {
+			r.nodes = append(r.nodes[:i], r.nodes[i+1:]...)
+		}
+	}
+	r.updateWeighted()
+}
+
+// 节点对key的得分，keyHash为key的哈希值。由节点和key的哈希值混合得到(0,1)内均匀分布的u，
+// 得分为 -weight/ln(u)，这样每个节点胜出的概率与权重成正比；权重都为1时得分随u单调递增，直接比较u
+func (n *rendezvousNode) score(keyHash uint32, weighted bool) float64 {
+	h := mix64(uint64(n.seed)<<32 | uint64(keyHash))
+	u := (float64(h>>11) + 0.5) / (1 << 53)
+	if !weighted {
+		return u
+	}
+	return -float64(n.weight) / math.Log(u)
+}
+
+// Get 返回得分最高的节点
+func (r *Rendezvous) Get(key string) string {
+	if len(r.nodes) == 0 {
+		return ""
+	}
+	keyHash := r.hash([]byte(key))
+	best, bestScore := 0, 0.0
+	for i := range r.nodes {
+		if s := r.nodes[i].score(keyHash, r.weighted); s > bestScore {
+			best, bestScore = i, s
+		}
+	}
+	return r.nodes[best].name
+}
+
+// GetN 按得分从高到低返回最多n个节点
+func (r *Rendezvous) GetN(key string, n int) []string {
+	if len(r.nodes) == 0 || n <= 0 {
+		return nil
+	}
+	keyHash := r.hash([]byte(key))
+	order := make([]int, len(r.nodes))
+	scores := make([]float64, len(r.nodes))
+	for i := range r.nodes {
+		order[i] = i
+		scores[i] = r.nodes[i].score(keyHash, r.weighted)
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	if n > len(order) {
+		n = len(order)
+	}
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = r.nodes[order[i]].name
+	}
+	return nodes
+}
+
+// 有权重不为1的节点时，所有节点都需要使用带权重的得分
+func (r *Rendezvous) updateWeighted() {
+	r.weighted = false
+	for i := range r.nodes {
+		if r.nodes[i].weight != 1 {
+			r.weighted = true
+		}
+	}
+}
+
+// Len 返回节点的数量
+func (r *Rendezvous) Len() int {
+	return len(r.nodes)
+}
@@ -0,0 +1,63 @@
+package consistenthash
+
+// Selector 根据key选择节点，HTTPPool 通过它决定key由哪个节点负责。
+// Map（虚拟节点哈希环）、Rendezvous（最高随机权重哈希）和 Jump（跳跃一致性哈希）都实现了该接口
+// 选择的结果只能取决于当前的节点集合和权重，不能取决于加入顺序，否则各节点对key的归属会不一致
+type Selector interface {
+	// Add 添加权重为1的节点
+	Add(nodes ...string)
+	// AddWeighted 添加一个权重为weight的节点，节点负责的key的比例与权重成正比
+	AddWeighted(node string, weight int)
+	// Remove 删除节点
+	Remove(nodes ...string)
+	// Get 返回负责key的节点，没有节点时返回空字符串
+	Get(key string) string
+	// GetN 返回最多n个不同的节点，第一个与 Get 的结果相同，后面的节点依次作为备选
+	GetN(key string, n int) []string
+	// Len 返回选择时参与计算的条目数量，即虚拟节点或带权重展开后的节点数量
+	Len() int
+}
+
+var _ Selector = (*Map)(nil)
+var _ Selector = (*Rendezvous)(nil)
+var _ Selector = (*Jump)(nil)
+
+// 32位整数的最终混合函数（MurmurHash3 fmix32），消除 crc32 等线性哈希的相关性
+func mix32(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// 64位整数的最终混合函数（SplitMix64）
+func mix64(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+// LoadReporter 由需要知道节点负载的Selector实现（如 NewBounded 创建的Map），
+// 调用方在向节点发出请求时调用 Inc，请求结束时调用 Done
+type LoadReporter interface {
+	Inc(node string)
+	Done(node string)
+}
+
+var _ LoadReporter = (*Map)(nil)
+
+// OwnerSelector 由结果与负载有关的Selector实现。Get 和 GetN 可以把读请求分流到负载较低的节点，
+// 但写入、删除和副本必须发给不随负载变化的归属节点，否则归属节点会保留旧值
+type OwnerSelector interface {
+	// Owner 返回key所属的节点，不考虑负载
+	Owner(key string) string
+	// OwnersN 按优先顺序返回key所属的最多n个不同节点，不考虑负载
+	OwnersN(key string, n int) []string
+}
+
+var _ OwnerSelector = (*Map)(nil)
@@ -0,0 +1,146 @@
+package consistenthash
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var selectors = []struct {
+	name string
+	new  func() Selector
+}{
+	{"ring-50", func() Selector { return New(50, nil) }},
+	{"ring-200", func() Selector { return New(200, nil) }},
+	{"ring64-200", func() Selector { return NewHash64(200, nil) }},
+	{"rendezvous", func() Selector { return NewRendezvous(nil) }},
+	{"jump", func() Selector { return NewJump(nil) }},
+}
+
+func nodeNames(n int) []string {
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("10.0.0.%d:8001", i)
+	}
+	return nodes
+}
+
+// 每个节点负责的key数量相对平均值的最大偏差
+func imbalance(s Selector, nodes []string, keys int) float64 {
+	counts := make(map[string]int)
+	for i := 0; i < keys; i++ {
+		counts[s.Get("key"+strconv.Itoa(i))]++
+	}
+	avg := float64(keys) / float64(len(nodes))
+	worst := 0.0
+	for _, node := range nodes {
+		worst = math.Max(worst, math.Abs(float64(counts[node])-avg)/avg)
+	}
+	return worst
+}
+
+// 对比各算法的均衡程度，以及增加一个节点时移动的key的比例（理想值为 1/11），
+// 新节点的名称排在最后，Jump按名称编号桶，只有这种情况移动最少。使用 go test -v -run SelectorComparison 查看结果
+func TestSelectorComparison(t *testing.T) {
+	const keys = 100000
+	for _, sel := range selectors {
+		s := sel.new()
+		nodes := nodeNames(10)
+		s.Add(nodes...)
+		worst := imbalance(s, nodes, keys)
+
+		before := make([]string, keys)
+		for i := range before {
+			before[i] = s.Get("key" + strconv.Itoa(i))
+		}
+		s.Add("10.0.1.0:8001")
+		moved := 0
+		for i := range before {
+			if got := s.Get("key" + strconv.Itoa(i)); got != before[i] {
+				if got != "10.0.1.0:8001" {
+					t.Fatalf("%s: key moved between old nodes: %s -> %s", sel.name, before[i], got)
+				}
+				moved++
+			}
+		}
+		t.Logf("%-10s imbalance %5.1f%%  moved %5.2f%%", sel.name, worst*100, float64(moved)/keys*100)
+
+		// 哈希环的均衡程度取决于虚拟节点数量和哈希函数，只记录结果；另外两种算法应接近完全均衡
+		if !strings.HasPrefix(sel.name, "ring") && worst > 0.05 {
+			t.Errorf("%s: imbalance %.2f is too high", sel.name, worst)
+		}
+		if ratio := float64(moved) / keys; ratio > 0.15 {
+			t.Errorf("%s: moved %.2f of the keys", sel.name, ratio)
+		}
+	}
+}
+
+// 测试所有算法的基本行为：GetN返回不同的节点，删除节点后不再被选中
+func TestSelectors(t *testing.T) {
+	for _, sel := range selectors {
+		s := sel.new()
+		if s.Get("Tom") != "" || s.GetN("Tom", 2) != nil {
+			t.Fatalf("%s: empty selector should return nothing", sel.name)
+		}
+		s.Add("a", "b", "c")
+		nodes := s.GetN("Tom", 5)
+		if len(nodes) != 3 || nodes[0] != s.Get("Tom") || nodes[0] == nodes[1] || nodes[1] == nodes[2] || nodes[0] == nodes[2] {
+			t.Fatalf("%s: GetN should return each node once, got %v", sel.name, nodes)
+		}
+		s.Remove(nodes[0])
+		if got := s.Get("Tom"); got != nodes[1] {
+			t.Fatalf("%s: Tom should fall back to %s after removing %s, got %s", sel.name, nodes[1], nodes[0], got)
+		}
+	}
+}
+
+func BenchmarkSelectorGet(b *testing.B) {
+	for _, n := range []int{10, 100} {
+		for _, sel := range selectors {
+			s := sel.new()
+			s.Add(nodeNames(n)...)
+			b.Run(fmt.Sprintf("%s/nodes=%d", sel.name, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					s.Get("key" + strconv.Itoa(i))
+				}
+			})
+		}
+	}
+}
+
+// 所有算法的结果都只取决于节点集合，与加入顺序和增删历史无关，否则各节点对key的归属会不一致
+func TestSelectorsOrder(t *testing.T) {
+	for _, sel := range selectors {
+		a, b := sel.new(), sel.new()
+		a.Add("a", "b", "c", "d")
+		b.Add("d", "b")
+		b.AddWeighted("c", 1)
+		b.Add("a")
+		b.Remove("b")
+		b.Add("b")
+		for i := 0; i < 1000; i++ {
+			key := "key" + strconv.Itoa(i)
+			if a.Get(key) != b.Get(key) {
+				t.Fatalf("%s: %s goes to %s and %s with different add orders", sel.name, key, a.Get(key), b.Get(key))
+			}
+		}
+	}
+}
+
+// 带权重时每个节点负责的key的比例应与权重成正比
+func TestSelectorsWeighted(t *testing.T) {
+	for _, sel := range selectors[1:] {
+		s := sel.new()
+		s.AddWeighted("small", 1)
+		s.AddWeighted("large", 3)
+		counts := make(map[string]int)
+		for i := 0; i < 40000; i++ {
+			counts[s.Get("key"+strconv.Itoa(i))]++
+		}
+		if got := counts["large"]; got < 27000 || got > 33000 {
+			t.Errorf("%s: large node with weight 3 got %d of 40000 keys", sel.name, got)
+		}
+	}
+}
@@ -2,9 +2,14 @@ package geecache
 
 import (
 	"Learning_Code/geecache/singleflight"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
+	"sort"
 	"sync"
+	"time"
 )
 
 //回调Getter
@@ -21,14 +26,98 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+//ContextGetter是Getter的可选扩展，加载数据时可以感知调用方的截止时间和取消信号
+//Group的getter实现了该接口时，优先调用GetContext
+type ContextGetter interface {
+	GetContext(ctx context.Context, key string) ([]byte, error)
+}
+
+//ContextGetterFunc同时实现了Getter和ContextGetter接口，可以直接传给NewGroup
+type ContextGetterFunc func(ctx context.Context, key string) ([]byte, error)
+
+//GetContext实现ContextGetter的接口函数
+func (f ContextGetterFunc) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+//Get实现Getter的接口函数，使用context.Background()
+func (f ContextGetterFunc) Get(key string) ([]byte, error) {
+	return f(context.Background(), key)
+}
+
 //核心数据结构Group
 //一个Group可以认为是缓存的命名空间，每个Group有唯一的name。比如可以有成绩sources，学生信息info
 type Group struct {
 	name      string
-	getter    Getter // 缓存未命中时的回调callback
-	mainCache cache  // 前面实现的并发缓存
+	getter    Getter  // 缓存未命中时的回调callback
+	mainCache cache   // 前面实现的并发缓存
+	hotCache  cache   // 缓存从远程节点获取的热点值，避免每次都访问网络
+	hotRatio  float64 // hotCache占cacheBytes的比例
+	hotSample int     // 从远程节点获取的值中，每hotSample个抽样一个放入hotCache
 	peers     PeerPicker
 	loader    *singleflight.Group // 用于保证每个key只访问一次
+	ttl       time.Duration       // 缓存条目的默认存活时间，0表示永不过期
+	stats     groupStats          // 统计数据，通过Stats()获取快照
+	negCache  *negativeCache      // 负缓存，为nil时不缓存加载失败的结果
+	refresh   *refreshAhead       // 后台刷新配置，为nil时条目过期后同步加载
+	replicas  int                 // 每个key的副本数量，小于等于1时只有一个节点负责
+
+	setter     Setter            // 写回数据源，为nil时不支持Set
+	writeMode  WriteMode         // 写入模式
+	writeQueue chan pendingWrite // WriteBehind模式下等待异步写入的队列
+	pending    sync.WaitGroup    // 尚未完成的异步写入
+
+	snapshotPath     string        // 快照文件的路径，为空时不保存快照
+	snapshotInterval time.Duration // 保存快照的间隔
+}
+
+const (
+	// 后台清理过期条目的最长间隔
+	defaultJanitorInterval = time.Minute
+	// 默认抽样1/10从远程节点获取的值放入hotCache
+	defaultHotCacheSample = 10
+)
+
+//GroupOption用于在NewGroup时定制Group的可选配置
+type GroupOption func(*Group)
+
+//WithTTL设置Group中缓存条目的默认存活时间，过期的条目在查找时惰性删除，并由后台协程定期清理
+func WithTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.ttl = ttl
+	}
+}
+
+//WithEvictionPolicy设置Group的缓存淘汰策略，默认为LRU
+func WithEvictionPolicy(newPolicy NewPolicyFunc) GroupOption {
+	return func(g *Group) {
+		g.mainCache.newPolicy = newPolicy
+	}
+}
+
+//WithShards将Group的缓存划分为n个分片，每个分片有独立的锁并平分cacheBytes，默认只有一个分片
+func WithShards(n int) GroupOption {
+	return func(g *Group) {
+		g.mainCache.nShards = n
+	}
+}
+
+//WithTinyLFU为Group启用TinyLFU准入策略：从数据源加载的新值只有比淘汰策略给出的淘汰对象访问更频繁时才会进入缓存，
+//避免批量扫描冲刷热点数据。samples为访问频率的统计窗口，通常取缓存预计条目数的10倍左右。
+//自定义的淘汰策略需要实现 Victim(key string, value lru.Value) (victim string, ok bool) 才支持准入策略，否则该选项不生效
+func WithTinyLFU(samples int) GroupOption {
+	return func(g *Group) {
+		g.mainCache.samples = samples
+	}
+}
+
+//WithHotCache设置hotCache占cacheBytes的比例ratio，以及抽样率1/sample，默认不启用hotCache，不缓存远程节点的值。
+//hotCache的容量在NewGroup时就从cacheBytes中划出，通常只在注册了远程节点的Group上启用，例如ratio取1/8
+func WithHotCache(ratio float64, sample int) GroupOption {
+	return func(g *Group) {
+		g.hotRatio = ratio
+		g.hotSample = sample
+	}
 }
 
 var (
@@ -37,7 +126,7 @@ var (
 )
 
 //NewGroup用于新建一个Group的实例
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+func NewGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) *Group {
 	//传入空Getter处理
 	if getter == nil {
 		panic("nil Getter")
@@ -52,8 +141,41 @@ func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
 		name:      name,
 		getter:    getter,
 		mainCache: cache{cacheBytes: cacheBytes},
+		hotSample: defaultHotCacheSample,
 		loader:    &singleflight.Group{},
 	}
+	//应用可选配置
+	for _, opt := range opts {
+		opt(g)
+	}
+	//从cacheBytes中划出hotCache的容量，必须在mainCache初始化分片之前完成，之后分片的容量不再变化
+	if hotBytes := int64(float64(cacheBytes) * g.hotRatio); hotBytes > 0 {
+		g.mainCache.cacheBytes -= hotBytes
+		g.hotCache.cacheBytes = hotBytes
+		g.hotCache.nShards = g.mainCache.nShards
+	}
+	//设置了TTL时启动后台清理协程，同名的Group被替换后协程退出
+	if g.ttl > 0 {
+		replaced := func() bool { return GetGroup(g.name) != g }
+		g.mainCache.startJanitor(g.janitorInterval(), replaced)
+		if g.hotCache.cacheBytes > 0 {
+			g.hotCache.startJanitor(g.janitorInterval(), replaced)
+		}
+	}
+	//WriteBehind模式下启动异步写入数据源的协程
+	if g.setter != nil && g.writeMode == WriteBehind {
+		g.writeQueue = make(chan pendingWrite, defaultWriteQueueSize)
+		go g.writeBehind()
+	}
+	//从快照恢复缓存，并定期保存快照
+	if g.snapshotPath != "" {
+		if err := g.restoreFile(g.snapshotPath); err != nil {
+			log.Println("[GeeCache] Failed to restore snapshot", err)
+		}
+		if g.snapshotInterval > 0 {
+			go g.snapshotLoop(g.snapshotPath, g.snapshotInterval)
+		}
+	}
 	//将这个Group加入到map映射中
 	groups[name] = g
 
@@ -68,22 +190,92 @@ func GetGroup(name string) *Group {
 	return g
 }
 
+//后台清理协程的执行间隔
+func (g *Group) janitorInterval() time.Duration {
+	if g.ttl > defaultJanitorInterval {
+		return defaultJanitorInterval
+	}
+	return g.ttl
+}
+
+//返回所有已注册的Group，按name排序
+func allGroups() []*Group {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+//GetContext与Get相同，但ctx会传递给Getter和远程节点，ctx结束时立即返回ctx.Err()
+func (g *Group) GetContext(ctx context.Context, key string) (ByteView, error) {
 	//空key处理
 	if key == "" {
 		return ByteView{}, fmt.Errorf("key is required")
 	}
 
+	g.stats.Gets.Add(1)
 	//如果缓存命中，写日志
-	if v, ok := g.mainCache.get(key); ok {
+	if v, ok := g.lookupCache(key); ok {
+		g.stats.CacheHits.Add(1)
 		log.Println("[GeeCache] hit")
+		g.maybeRefresh(key, v)
 		return v, nil
 	}
+	//负缓存命中，说明最近加载过该key且失败了，直接返回错误
+	if err, ok := g.negCache.get(key); ok {
+		g.stats.NegativeHits.Add(1)
+		return ByteView{}, err
+	}
 	//如果缓存未命中，需要加载
-	return g.load(key)
+	g.stats.Loads.Add(1)
+	return g.load(ctx, key)
+}
+
+// Remove 从缓存中删除key。若key属于远程节点，同时通知该节点删除，保证下次Get会重新加载
+func (g *Group) Remove(key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+
+	// 启用副本时删除所有副本
+	if replicas := g.pickReplicas(key); len(replicas) > 0 {
+		return g.removeReplicated(key, replicas)
+	}
+
+	// 先删除本地的缓存
+	g.removeLocally(key)
+
+	// 再通知key所属的远程节点，节点不可用时返回错误，而不是只删除了本地的缓存
+	if g.peers != nil {
+		if peer, ok := pickOwner(g.peers, key); ok {
+			remover, ok := peer.(PeerRemover)
+			if !ok {
+				return fmt.Errorf("peer does not support remove")
+			}
+			return remover.Remove(g.name, key)
+		}
+	}
+	return nil
 }
 
-// RegisterPeers()方法，将 实现了 PeerPicker 接口的 HTTPPool 注入到 Group 中。
+// 只删除本地缓存，用于处理其他节点转发来的删除请求
+func (g *Group) removeLocally(key string) {
+	g.mainCache.remove(key)
+	g.negCache.remove(key)
+	if g.hotCache.cacheBytes > 0 {
+		g.hotCache.remove(key)
+	}
+}
+
+// RegisterPeers()方法，将 实现了 PeerPicker 接口的 HTTPPool 注入到 Group 中
 func (g *Group) RegisterPeers(peers PeerPicker) {
 	// 如果已有注入过的PeerPicker，报错
 	if g.peers != nil {
@@ -93,47 +285,114 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 	g.peers = peers
 }
 
+// 依次在mainCache和hotCache中查找key
+func (g *Group) lookupCache(key string) (value ByteView, ok bool) {
+	if value, ok = g.mainCache.get(key); ok {
+		return
+	}
+	if g.hotCache.cacheBytes > 0 {
+		value, ok = g.hotCache.get(key)
+	}
+	return
+}
+
+// key是否在hotCache中
+func (g *Group) inHotCache(key string) bool {
+	_, ok := g.hotCache.get(key)
+	return ok
+}
+
 // 使用 PickPeer() 方法选择节点，若非本机节点，则调用 getFromPeer() 从远程获取。若是本机节点或失败，则回退到 getLocally()
-func (g *Group) load(key string) (value ByteView, err error) {
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
 	// 无论并发请求有多少（本地或远程都是），每个key只获取一次
-	viewi, err := g.loader.Do(key, func() (interface{}, error) {
+	// 所有等待者都离开后，传给fn的context会被取消
+	viewi, err, shared := g.loader.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+		g.stats.LoadsDeduped.Add(1)
 		// 传入匿名函数，并判断从哪个节点获取val
-		if g.peers != nil {
+		if g.peers != nil && !isLocalLoad(ctx) {
+			if replicas := g.pickReplicas(key); len(replicas) > 0 {
+				return g.loadReplicated(ctx, key, replicas)
+			}
 			if peer, ok := g.peers.PickPeer(key); ok {
-				if value, err = g.getFromPeer(peer, key); err == nil {
+				value, err := g.getFromPeer(ctx, peer, key)
+				if err == nil {
+					g.stats.PeerLoads.Add(1)
 					return value, nil
 				}
+				// 远程节点确认key不存在，不需要再从本地加载
+				if errors.Is(err, ErrNotFound) {
+					g.stats.PeerLoads.Add(1)
+					g.negCache.add(key, err)
+					return nil, err
+				}
+				g.stats.PeerErrors.Add(1)
 				log.Println("[GeeCache] Failed to get from peer", err)
 			}
 		}
 		// 从本地获取val
-		return g.getLocally(key)
+		return g.loadLocally(ctx, key)
 	})
 
+	// 结果与其他并发请求共享，说明这次加载被去重了
+	if shared {
+		g.stats.LoadsShared.Add(1)
+	}
 	if err == nil {
 		return viewi.(ByteView), nil
 	}
 	return
 }
 
+// 从本地数据源加载key并记录统计数据，加载失败的结果放入负缓存
+func (g *Group) loadLocally(ctx context.Context, key string) (ByteView, error) {
+	value, err := g.getLocally(ctx, key)
+	if err != nil {
+		g.stats.LocalLoadErrs.Add(1)
+		// 调用方取消导致的错误与key无关，不缓存
+		if ctx.Err() == nil {
+			g.negCache.add(key, err)
+		}
+		return ByteView{}, err
+	}
+	g.stats.LocalLoads.Add(1)
+	return value, nil
+}
+
 // 使用实现了 PeerGetter 接口的 httpGetter 从访问远程节点，获取缓存值。
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
-	bytes, err := peer.Get(g.name, key)
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
+	bytes, err := getFromPeer(ctx, peer, g.name, key)
 	if err != nil {
 		return ByteView{}, err
 	}
-	return ByteView{bytes}, nil
+	return g.peerValue(key, bytes), nil
 }
 
-func (g *Group) getLocally(key string) (ByteView, error) {
-	//调用Getter接口的Get方法
-	bytes, err := g.getter.Get(key)
+// 包装从远程节点获取的值，并抽样放入hotCache
+func (g *Group) peerValue(key string, bytes []byte) ByteView {
+	value := ByteView{b: bytes}
+	// 抽样一部分远程节点的值放入hotCache，越热的key越容易被抽中；已在hotCache中的key（后台刷新）直接更新
+	if g.hotCache.cacheBytes > 0 && (g.hotSample <= 1 || rand.Intn(g.hotSample) == 0 || g.inHotCache(key)) {
+		value.e = g.expireAt()
+		g.hotCache.add(key, value)
+	}
+	return value
+}
+
+func (g *Group) getLocally(ctx context.Context, key string) (ByteView, error) {
+	//调用Getter接口的Get方法，getter支持context时调用GetContext
+	var bytes []byte
+	var err error
+	if cg, ok := g.getter.(ContextGetter); ok {
+		bytes, err = cg.GetContext(ctx, key)
+	} else {
+		bytes, err = g.getter.Get(key)
+	}
 	if err != nil {
 		return ByteView{}, err
 	}
 
-	//value为返回信息的副本
-	value := ByteView{cloneBytes(bytes)}
+	//value为返回信息的副本，设置了TTL时附带过期时间
+	value := ByteView{b: cloneBytes(bytes), e: g.expireAt()}
 	//调用pupulateCache调整cache
 	g.populateCache(key, value)
 
@@ -141,6 +400,6 @@ func (g *Group) getLocally(key string) (ByteView, error) {
 }
 
 func (g *Group) populateCache(key string, value ByteView) {
-	//调用cache封装的add方法，包含了移动链表元素、检测是否超过容量等操作
-	g.mainCache.add(key, value)
+	//调用cache封装的admit方法，包含了准入判断、移动链表元素、检测是否超过容量等操作
+	g.mainCache.admit(key, value)
 }
@@ -1,10 +1,15 @@
 package geecache
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 //测试回调函数
@@ -69,3 +74,260 @@ func TestGet(t *testing.T) {
 		t.Fatalf("the value of unknow should be empty, but %s got", view)
 	}
 }
+
+//测试缓存条目的过期
+func TestGetWithTTL(t *testing.T) {
+	loads := 0
+	gee := NewGroup("ttl", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}), WithTTL(50*time.Millisecond))
+
+	if view, err := gee.Get("Tom"); err != nil || view.String() != "Tom" || view.Expire().IsZero() {
+		t.Fatalf("failed to get value of Tom")
+	}
+	if _, err := gee.Get("Tom"); err != nil || loads != 1 {
+		t.Fatalf("cache Tom miss")
+	}
+
+	//等待条目过期，再次Get需要重新加载
+	time.Sleep(100 * time.Millisecond)
+	if _, err := gee.Get("Tom"); err != nil || loads != 2 {
+		t.Fatalf("Tom should be reloaded after expiration, loads = %d", loads)
+	}
+}
+
+//测试为Group选择不同的淘汰策略
+func TestEvictionPolicy(t *testing.T) {
+	policies := map[string]NewPolicyFunc{"lru": LRU, "lfu": LFU, "arc": ARC, "2q": TwoQ}
+	for name, policy := range policies {
+		loads := 0
+		gee := NewGroup("policy-"+name, 2<<10, GetterFunc(
+			func(key string) ([]byte, error) {
+				loads++
+				return []byte(key), nil
+			}), WithEvictionPolicy(policy))
+
+		for _, k := range []string{"Tom", "Jack", "Tom", "Jack"} {
+			if view, err := gee.Get(k); err != nil || view.String() != k {
+				t.Fatalf("%s: failed to get value of %s", name, k)
+			}
+		}
+		if loads != 2 {
+			t.Fatalf("%s: expect 2 loads, got %d", name, loads)
+		}
+	}
+}
+
+//测试TinyLFU准入策略：批量扫描不会冲刷热点key，每种淘汰策略都支持准入策略
+func TestTinyLFU(t *testing.T) {
+	policies := map[string]NewPolicyFunc{"lru": LRU, "lfu": LFU, "arc": ARC, "twoq": TwoQ}
+	for name, policy := range policies {
+		loads := make(map[string]int)
+		//每个条目6字节，容量可容纳4个条目
+		gee := NewGroup("tinylfu-"+name, 24, GetterFunc(
+			func(key string) ([]byte, error) {
+				loads[key]++
+				return []byte("vvvv"), nil
+			}), WithTinyLFU(1000), WithEvictionPolicy(policy))
+
+		for i := 0; i < 3; i++ {
+			for _, k := range []string{"h1", "h2", "h3", "h4"} {
+				gee.Get(k)
+			}
+		}
+		for i := 0; i < 100; i++ {
+			gee.Get("s" + strconv.Itoa(i))
+		}
+		for _, k := range []string{"h1", "h2", "h3", "h4"} {
+			if gee.Get(k); loads[k] != 1 {
+				t.Fatalf("%s: hot key %s should survive the scan, loads = %d", name, k, loads[k])
+			}
+		}
+	}
+}
+
+//用于测试的PeerPicker，所有key都属于同一个远程节点
+type fakePeer struct {
+	gets    int
+	removed []string
+	set     map[string]string
+}
+
+func (p *fakePeer) PickPeer(key string) (PeerGetter, bool) {
+	return p, true
+}
+
+func (p *fakePeer) Get(group string, key string) ([]byte, error) {
+	p.gets++
+	return []byte(key), nil
+}
+
+func (p *fakePeer) Set(group string, key string, value []byte) error {
+	if p.set == nil {
+		p.set = make(map[string]string)
+	}
+	p.set[key] = string(value)
+	return nil
+}
+
+func (p *fakePeer) Remove(group string, key string) error {
+	p.removed = append(p.removed, key)
+	return nil
+}
+
+//测试Remove同时删除本地缓存并通知远程节点
+func TestRemove(t *testing.T) {
+	loads := 0
+	gee := NewGroup("remove", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+
+	gee.Get("Tom")
+	if err := gee.Remove("Tom"); err != nil {
+		t.Fatalf("failed to remove Tom: %v", err)
+	}
+	if gee.Get("Tom"); loads != 2 {
+		t.Fatalf("Tom should be reloaded after remove, loads = %d", loads)
+	}
+
+	peer := &fakePeer{}
+	gee.RegisterPeers(peer)
+	if err := gee.Remove("Tom"); err != nil || !reflect.DeepEqual(peer.removed, []string{"Tom"}) {
+		t.Fatalf("remove should be forwarded to the owning peer")
+	}
+}
+
+//测试Group的统计数据
+func TestStats(t *testing.T) {
+	gee := NewGroup("stats", 16, GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "unknown" {
+				return nil, fmt.Errorf("%s not exist", key)
+			}
+			return []byte("value"), nil
+		}))
+
+	gee.Get("k1")
+	gee.Get("k1")
+	gee.Get("k2")
+	gee.Get("unknown")
+	gee.Remove("k2")
+
+	//k1、k2各占7字节，加入k2时容量未超出，不会淘汰
+	expect := Stats{
+		Gets:          4,
+		CacheHits:     1,
+		Loads:         3,
+		LoadsDeduped:  3,
+		LocalLoads:    2,
+		LocalLoadErrs: 1,
+		MainCache:     CacheStats{Items: 1, Bytes: 7},
+	}
+	if st := gee.Stats(); !reflect.DeepEqual(st, expect) {
+		t.Fatalf("expect stats %+v, got %+v", expect, st)
+	}
+
+	//再加入k3、k4后超出16字节，淘汰最久未使用的k1
+	gee.Get("k3")
+	gee.Get("k4")
+	if st := gee.Stats(); st.MainCache.Evictions != 1 || st.MainCache.Items != 2 {
+		t.Fatalf("expect 1 eviction and 2 items, got %+v", st.MainCache)
+	}
+}
+
+//测试从远程节点获取的值被放入hotCache
+func TestHotCache(t *testing.T) {
+	gee := NewGroup("hot", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			t.Fatalf("key %s should be loaded from peer", key)
+			return nil, nil
+		}), WithHotCache(0.5, 1))
+	//mainCache在注册远程节点之前已经初始化，容量仍然不包括hotCache的部分
+	gee.mainCache.add("Jack", ByteView{b: []byte("589")})
+	peer := &fakePeer{}
+	gee.RegisterPeers(peer)
+	if gee.mainCache.shards[0].cacheBytes != 1<<10 || gee.hotCache.cacheBytes != 1<<10 {
+		t.Fatalf("hot cache should take half of cacheBytes")
+	}
+	gee.mainCache.remove("Jack")
+
+	for i := 0; i < 3; i++ {
+		if view, err := gee.Get("Tom"); err != nil || view.String() != "Tom" {
+			t.Fatalf("failed to get value of Tom")
+		}
+	}
+	if peer.gets != 1 {
+		t.Fatalf("Tom should be fetched from peer once, got %d", peer.gets)
+	}
+	if st := gee.Stats(); st.HotCache.Items != 1 || st.MainCache.Items != 0 {
+		t.Fatalf("Tom should only be cached in hot cache, got %+v", st)
+	}
+
+	//删除时同时删除hotCache中的值
+	gee.Remove("Tom")
+	if gee.Get("Tom"); peer.gets != 2 {
+		t.Fatalf("Tom should be fetched again after remove")
+	}
+}
+
+//测试并发加载同一个key时只加载一次，并统计共享了结果的加载
+func TestLoadsShared(t *testing.T) {
+	release := make(chan struct{})
+	gee := NewGroup("shared", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			<-release
+			return []byte(key), nil
+		}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gee.Get("Tom")
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if st := gee.Stats(); st.LoadsDeduped != 1 || st.LoadsShared != 5 {
+		t.Fatalf("expect 1 deduped load shared by 5 callers, got %+v", st)
+	}
+}
+
+//测试ctx传递给ContextGetter，ctx结束时GetContext立即返回
+func TestGetContext(t *testing.T) {
+	type ctxKey struct{}
+	gee := NewGroup("context", 2<<10, ContextGetterFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			if key == "slow" {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return []byte(ctx.Value(ctxKey{}).(string)), nil
+		}))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "630")
+	if view, err := gee.GetContext(ctx, "Tom"); err != nil || view.String() != "630" {
+		t.Fatalf("ctx should be passed to the Getter")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := gee.GetContext(ctx, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+
+	//普通的Getter仍然可用
+	var getter Getter = ContextGetterFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	if v, _ := getter.Get("key"); string(v) != "key" {
+		t.Fatalf("ContextGetterFunc should implement Getter")
+	}
+}
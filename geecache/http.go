@@ -2,18 +2,32 @@ package geecache
 
 import (
 	"Learning_Code/geecache/consistenthash"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
 	defaultBasePath = "/_geecache/"
 	defaultReplicas = 50
+	// 远程节点的数据源中不存在key时，404响应会带上该响应头
+	notFoundHeader = "X-Geecache-Not-Found"
+	// 主节点不可用、由哈希环上后面的节点代为加载时，请求会带上该响应头，收到的节点不再转发
+	fallbackHeader = "X-Geecache-Fallback"
+	// 复制副本的PUT请求带上该请求头，收到的节点只更新缓存，不写数据源
+	replicaHeader = "X-Geecache-Replica"
 )
 
 // 服务端
@@ -21,22 +35,96 @@ const (
 // HTTPPool implements PeerPicker for a pool of HTTP peers.
 type HTTPPool struct {
 	// this peer's base URL, e.g. "https://example.net:8000"
-	self        string                 // 记录自己的地址，包括主机名/IP和端口
-	basePath    string                 // 节点间通讯地址的前缀，默认是/_geecache/
-	mu          sync.Mutex             // 保护peers和httpGetters
-	peers       *consistenthash.Map    // 根据具体的key选择节点
-	httpGetters map[string]*httpGetter // 映射远程节点与对应的httpGetter e.g. "http://10.0.0.2:8008"
+	self        string                         // 记录自己的地址，包括主机名/IP和端口
+	basePath    string                         // 节点间通讯地址的前缀，默认是/_geecache/
+	metricsPath string                         // 导出监控指标的地址，默认是/metrics
+	mu          sync.Mutex                     // 保护peers和httpGetters
+	peers       consistenthash.Selector        // 根据具体的key选择节点
+	httpGetters map[string]*httpGetter         // 映射远程节点与对应的httpGetter e.g. "http://10.0.0.2:8008"
+	timeout     time.Duration                  // 每次请求远程节点的超时时间，0表示不超时
+	retries     int                            // 请求失败后的最大重试次数
+	backoff     time.Duration                  // 第一次重试前的等待时间，之后每次翻倍
+	fallback    int                            // 主节点失败后，依次尝试哈希环上后面的fallback个节点
+	chains      map[string]*peerChain          // 复用节点序列相同的peerChain，使GetMulti可以按peerChain分组
+	weights     map[string]int                 // 哈希环上每个节点的权重
+	newSelector func() consistenthash.Selector // 创建选择节点的算法，默认为虚拟节点哈希环
+
+	breakerThreshold int           // 连续失败多少次后断开节点的熔断器，小于等于0时不启用
+	breakerCoolDown  time.Duration // 熔断器断开后多久允许试探请求
 
 	//那么 http://example.com/_geecache/ 开头的请求，就用于节点间的访问。
 	//因为一个主机上还可能承载其他的服务，加一段 Path 是一个好习惯。比如，大部分网站的 API 接口，一般以 /api 作为前缀
 }
 
+// HTTPPoolOption 用于在 NewHTTPPool 时定制访问远程节点的方式
+type HTTPPoolOption func(*HTTPPool)
+
+// WithPeerTimeout 设置每次请求远程节点的超时时间，重试时每次尝试单独计时
+func WithPeerTimeout(timeout time.Duration) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.timeout = timeout
+	}
+}
+
+// WithPeerRetries 设置请求远程节点失败后最多重试n次，第i次重试前等待约backoff*2^i，并加入随机抖动避免同时重试。
+// 远程节点返回4xx或 ErrNotFound 时不重试
+func WithPeerRetries(n int, backoff time.Duration) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.retries = n
+		p.backoff = backoff
+	}
+}
+
+// WithPeerFallback 设置主节点失败后，依次尝试哈希环上后面的n个节点，都失败时才回退到本地加载。
+// 代为加载的节点直接从自己的数据源加载并缓存，避免一个节点宕机时其他所有节点都访问数据源
+func WithPeerFallback(n int) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.fallback = n
+	}
+}
+
+// WithPeerCircuitBreaker 设置远程节点连续失败threshold次后熔断，coolDown内 PickPeer 跳过该节点，
+// 之后放行一个试探请求，成功后恢复。默认连续失败5次熔断10秒，threshold小于等于0时不启用
+func WithPeerCircuitBreaker(threshold int, coolDown time.Duration) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.breakerThreshold = threshold
+		p.breakerCoolDown = coolDown
+	}
+}
+
+// WithSelector 设置选择节点的算法，newSelector在第一次加入节点时调用，例如
+//
+//	NewHTTPPool(self, WithSelector(func() consistenthash.Selector { return consistenthash.NewRendezvous(nil) }))
+//
+// 默认使用 consistenthash.New(50, nil)。所有节点必须使用相同的算法，否则对key的归属会不一致
+func WithSelector(newSelector func() consistenthash.Selector) HTTPPoolOption {
+	return func(p *HTTPPool) {
+		p.newSelector = newSelector
+	}
+}
+
+// WithBoundedLoad 使用有界负载的一致性哈希（consistenthash.NewBounded）选择节点：
+// 某个节点正在处理的请求数超过平均值的(1+epsilon)倍时，它负责的key暂时交给哈希环上后面的节点。
+// 负载由本节点发出的请求统计，不包括其他节点发来的请求
+func WithBoundedLoad(epsilon float64) HTTPPoolOption {
+	return WithSelector(func() consistenthash.Selector {
+		return consistenthash.NewBounded(defaultReplicas, epsilon, nil)
+	})
+}
+
 // NewHTTPPool initializes an HTTP pool of peers.
-func NewHTTPPool(self string) *HTTPPool {
-	return &HTTPPool{
-		self:     self,
-		basePath: defaultBasePath,
+func NewHTTPPool(self string, opts ...HTTPPoolOption) *HTTPPool {
+	p := &HTTPPool{
+		self:             self,
+		basePath:         defaultBasePath,
+		metricsPath:      defaultMetricsPath,
+		breakerThreshold: defaultBreakerThreshold,
+		breakerCoolDown:  defaultBreakerCoolDown,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // Log函数用于按格式打印日志
@@ -47,6 +135,11 @@ func (p *HTTPPool) Log(format string, v ...interface{}) {
 //实现ServeHTTP方法，任何实现该方法的对象都可以作为HTTP的Handler
 //log info with server name
 func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	//监控指标与节点间通讯共用同一个Handler
+	if r.URL.Path == p.metricsPath {
+		p.serveMetrics(w, r)
+		return
+	}
 	//判断访问路径是否前缀是否为 basePath
 	if !strings.HasPrefix(r.URL.Path, p.basePath) {
 		panic("HTTPPool serving unexpected path: " + r.URL.Path)
@@ -75,8 +168,62 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//调用group实现的Get方法获取已经缓存的kv
-	view, err := group.Get(key)
+	//其他节点代替不可用的主节点发来的请求，直接从本地加载
+	ctx := r.Context()
+	if r.Header.Get(fallbackHeader) != "" {
+		ctx = withLocalLoad(ctx)
+	}
+
+	//根据请求方法处理：GET获取缓存值，PUT写入新值，DELETE删除缓存值
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		//由本节点写入数据源并更新缓存，不再转发
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		//其他节点复制来的副本只放入缓存
+		if r.Header.Get(replicaHeader) != "" {
+			group.fillLocally(key, body)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err := group.setLocally(key, body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodDelete:
+		//只删除本地缓存，不再转发，避免节点之间互相转发
+		group.removeLocally(key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodPost:
+		//批量获取，访问路径为 /<basepath>/<groupname>/，请求体为JSON格式的key列表
+		if key != "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		p.serveMulti(w, r.WithContext(ctx), group)
+		return
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	group.stats.ServerRequests.Add(1)
+	//调用group实现的GetContext方法获取已经缓存的kv，请求取消时停止加载
+	view, err := group.GetContext(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		//用响应头区分key不存在与group不存在，使对端可以缓存这个结果
+		w.Header().Set(notFoundHeader, "1")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -87,58 +234,383 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write(view.ByteSlice())
 }
 
-// 实例化一致性哈希，并添加节点
+// 处理批量获取请求，响应体为JSON格式的key到值的映射，不包含不存在的key
+func (p *HTTPPool) serveMulti(w http.ResponseWriter, r *http.Request, group *Group) {
+	var keys []string
+	if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	group.stats.ServerRequests.Add(1)
+	views, err := group.GetMultiContext(r.Context(), keys)
+	if err != nil {
+		//部分key失败时整个请求失败，由对端回退到本地加载
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	values := make(map[string][]byte, len(views))
+	for key, view := range views {
+		values[key] = view.ByteSlice()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(values)
+}
+
+// 设置节点列表，与当前列表相比只增删有变化的节点，保留的节点继续使用原来的httpGetter
 func (p *HTTPPool) Set(peers ...string) {
+	weights := make(map[string]int, len(peers))
+	for _, peer := range peers {
+		weights[peer] = 1
+	}
+	p.SetWeighted(weights)
+}
+
+// SetWeighted 与 Set 相同，但每个节点带有权重，节点负责的key的比例与权重成正比，
+// 通常按机器的内存大小设置。权重小于等于0的节点不会加入哈希环
+func (p *HTTPPool) SetWeighted(peers map[string]int) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	// 初始化一个一致性哈希的Map，并调用Add函数增加节点
-	p.peers = consistenthash.New(defaultReplicas, nil)
-	p.peers.Add(peers...)
-	// 建立每个peer与httpGetter的映射
-	p.httpGetters = make(map[string]*httpGetter, len(peers))
+
+	var removed []string
+	for peer, weight := range p.weights {
+		switch {
+		case peers[peer] <= 0:
+			removed = append(removed, peer)
+		case peers[peer] != weight:
+			// 权重变化时只从哈希环上删除，稍后按新权重加入，httpGetter保持不变
+			p.peers.Remove(peer)
+			delete(p.weights, peer)
+		}
+	}
+	p.removePeers(removed)
+	for peer, weight := range peers {
+		p.addPeer(peer, weight)
+	}
+}
+
+// AddPeers 向哈希环中加入新节点，已有的节点保持不变，只有新节点接管的key会移动
+func (p *HTTPPool) AddPeers(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.addPeers(peers)
+}
+
+// RemovePeers 从哈希环中删除节点，只有这些节点负责的key会移动到其他节点
+func (p *HTTPPool) RemovePeers(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removePeers(peers)
+}
+
+// 调用方需持有锁
+func (p *HTTPPool) addPeers(peers []string) {
+	for _, peer := range peers {
+		p.addPeer(peer, 1)
+	}
+}
+
+// 将节点以权重weight加入哈希环，已在环上的节点保持不变，调用方需持有锁
+func (p *HTTPPool) addPeer(peer string, weight int) {
+	// 延迟初始化一致性哈希的Map和peer与httpGetter的映射
+	if p.peers == nil {
+		if p.newSelector != nil {
+			p.peers = p.newSelector()
+		} else {
+			p.peers = consistenthash.New(defaultReplicas, nil)
+		}
+		p.httpGetters = make(map[string]*httpGetter)
+		p.weights = make(map[string]int)
+	}
+	if _, ok := p.weights[peer]; ok || weight <= 0 {
+		return
+	}
+	p.peers.AddWeighted(peer, weight)
+	p.weights[peer] = weight
+	if _, ok := p.httpGetters[peer]; !ok {
+		p.httpGetters[peer] = &httpGetter{
+			baseURL: peer + p.basePath,
+			latency: newHistogram(),
+			timeout: p.timeout,
+			retries: p.retries,
+			backoff: p.backoff,
+			breaker: newBreaker(p.breakerThreshold, p.breakerCoolDown),
+			node:    peer,
+		}
+		if loads, ok := p.peers.(consistenthash.LoadReporter); ok {
+			p.httpGetters[peer].loads = loads
+		}
+	}
+	// 哈希环变化后节点序列可能不同
+	p.chains = make(map[string]*peerChain)
+}
+
+// 调用方需持有锁
+func (p *HTTPPool) removePeers(peers []string) {
+	if p.peers == nil {
+		return
+	}
 	for _, peer := range peers {
-		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.basePath}
+		if _, ok := p.httpGetters[peer]; !ok {
+			continue
+		}
+		p.peers.Remove(peer)
+		delete(p.httpGetters, peer)
+		delete(p.weights, peer)
+	}
+	p.chains = make(map[string]*peerChain)
+}
+
+// 返回排序后的远程节点列表，调用方需持有锁
+func (p *HTTPPool) sortedPeers() []string {
+	peers := make([]string, 0, len(p.httpGetters))
+	for peer := range p.httpGetters {
+		peers = append(peers, peer)
 	}
+	sort.Strings(peers)
+	return peers
 }
 
 // 实现PeerPicker接口，通过key选择对应的peer，返回节点对应的 HTTP 客户端。
 func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	// 通过一致性哈希环找到应该读取的节点
-	if peer := p.peers.Get(key); peer != "" && peer != p.self {
-		p.Log("Pick peer %s", peer)
-		return p.httpGetters[peer], true
+	// 通过一致性哈希环找到应该读取的节点，有界负载模式下可能不是key所属的节点
+	peer := p.peers.Get(key)
+	if peer == "" || peer == p.self {
+		return nil, false
+	}
+	owner := p.owner(key)
+	if owner == p.self {
+		return nil, false
+	}
+	candidates := []string{peer}
+	if p.fallback > 0 {
+		candidates = p.peers.GetN(key, p.fallback+1)
+	}
+
+	// 依次加入哈希环上后面的节点，跳过熔断器断开的节点，遇到自己时停止，由自己从本地加载
+	var nodes []string
+	for _, node := range candidates {
+		if node == p.self {
+			break
+		}
+		if !p.httpGetters[node].breaker.ready() {
+			p.Log("Skip peer %s, circuit breaker is open", node)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return nil, false
+	}
+	p.Log("Pick peer %s", nodes[0])
+	if len(nodes) == 1 && nodes[0] == owner {
+		return p.httpGetters[owner], true
+	}
+	// 所属节点被跳过或因负载过高被分流时也返回peerChain：其他节点收到的请求标记为从本地加载，
+	// 不会再转发回所属节点，写入和删除仍然发给所属节点
+	id := owner + ">" + strings.Join(nodes, " ")
+	chain, ok := p.chains[id]
+	if !ok {
+		chain = &peerChain{owner: p.httpGetters[owner]}
+		for _, node := range nodes {
+			chain.getters = append(chain.getters, p.httpGetters[node])
+		}
+		p.chains[id] = chain
+	}
+	return chain, true
+}
+
+// 实现OwnerPicker接口，返回哈希环上key所属的节点，不跳过熔断器断开的节点。
+// 该节点的熔断器断开时，写入和删除请求直接返回 errCircuitOpen
+func (p *HTTPPool) PickOwner(key string) (PeerGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	peer := p.owner(key)
+	if peer == "" || peer == p.self {
+		return nil, false
+	}
+	return p.httpGetters[peer], true
+}
+
+// 实现MultiPeerPicker接口，按哈希环上的顺序返回key的n个副本节点，本节点用nil表示。
+// 熔断器断开的节点不会被跳过：写入和删除必须覆盖所有副本，访问这些节点时直接返回 errCircuitOpen；
+// 有界负载模式下也不考虑负载，副本集合和主节点保持不变
+func (p *HTTPPool) PickPeers(key string, n int) []PeerGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var peers []PeerGetter
+	for _, node := range p.ownersN(key, n) {
+		if node == p.self {
+			peers = append(peers, nil)
+		} else {
+			peers = append(peers, p.httpGetters[node])
+		}
 	}
+	return peers
+}
 
-	return nil, false
+// 返回key所属的节点，不考虑负载，调用方需持有锁
+func (p *HTTPPool) owner(key string) string {
+	if s, ok := p.peers.(consistenthash.OwnerSelector); ok {
+		return s.Owner(key)
+	}
+	return p.peers.Get(key)
+}
+
+// 返回key所属的n个节点，不考虑负载，调用方需持有锁
+func (p *HTTPPool) ownersN(key string, n int) []string {
+	if s, ok := p.peers.(consistenthash.OwnerSelector); ok {
+		return s.OwnersN(key, n)
+	}
+	return p.peers.GetN(key, n)
 }
 
 var _ PeerPicker = (*HTTPPool)(nil)
+var _ MultiPeerPicker = (*HTTPPool)(nil)
+var _ OwnerPicker = (*HTTPPool)(nil)
 
 // 客户端
 
 type httpGetter struct {
-	baseURL string //baseURL 表示将要访问的远程节点的地址，例如 http://example.com/_geecache/
+	baseURL string        //baseURL 表示将要访问的远程节点的地址，例如 http://example.com/_geecache/
+	latency *histogram    // 请求耗时的分布，由 /metrics 导出
+	timeout time.Duration // 每次请求的超时时间，0表示不超时
+	retries int           // 失败后的最大重试次数
+	backoff time.Duration // 第一次重试前的等待时间
+	breaker *breaker      // 熔断器，为nil时不启用
+
+	node  string                      // 节点在Selector中的名称
+	loads consistenthash.LoadReporter // 请求开始和结束时报告节点的负载，为nil时不报告
 }
 
-// 实现PeerGetter接口的Get方法
-func (h *httpGetter) Get(group string, key string) ([]byte, error) {
-	u := fmt.Sprintf(
-		"%v%v%v",
+// 远程节点返回了非预期的状态码
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "server returned: " + e.status
+}
+
+// 网络错误、超时和5xx可以重试，远程节点明确拒绝的请求和不存在的key不重试
+func retryable(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500
+	}
+	return true
+}
+
+// 执行fn，设置了超时时间时每次尝试单独计时，失败时按指数退避加随机抖动重试
+// 熔断器断开时不发出请求，直接返回 errCircuitOpen
+func (h *httpGetter) retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !h.breaker.allow() {
+		return errCircuitOpen
+	}
+	if h.loads != nil {
+		h.loads.Inc(h.node)
+		defer h.loads.Done(h.node)
+	}
+	err := h.retryLoop(ctx, fn)
+	// 调用方取消的请求不能说明节点的状态
+	if ctx.Err() == nil {
+		h.breaker.record(err != nil && retryable(err))
+	}
+	return err
+}
+
+func (h *httpGetter) retryLoop(ctx context.Context, fn func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		err := h.attempt(ctx, fn)
+		if err == nil || attempt >= h.retries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-time.After(h.backoffFor(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (h *httpGetter) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+// 第attempt次重试前的等待时间，在 [d/2, d) 之间随机取值，d = backoff*2^attempt
+func (h *httpGetter) backoffFor(attempt int) time.Duration {
+	d := h.backoff << uint(attempt)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// 记录从start开始的请求耗时
+func (h *httpGetter) observe(start time.Time) {
+	if h.latency != nil {
+		h.latency.observe(time.Since(start))
+	}
+}
+
+// 拼接访问远程节点的地址，格式为 <baseURL><group>/<key>
+func (h *httpGetter) url(group string, key string) string {
+	return fmt.Sprintf(
+		"%v%v/%v",
 		h.baseURL,
 		url.QueryEscape(group),
 		url.QueryEscape(key),
 	)
-	// 使用 http.Get() 方式获取返回值，并转换为 []bytes 类型。
-	res, err := http.Get(u)
+}
+
+// 实现PeerGetter接口的Get方法
+func (h *httpGetter) Get(group string, key string) ([]byte, error) {
+	return h.GetContext(context.Background(), group, key)
+}
+
+// 实现ContextPeerGetter接口的GetContext方法，ctx结束时请求会被取消
+func (h *httpGetter) GetContext(ctx context.Context, group string, key string) ([]byte, error) {
+	var bytes []byte
+	err := h.retry(ctx, func(ctx context.Context) (err error) {
+		bytes, err = h.get(ctx, group, key)
+		return err
+	})
+	return bytes, err
+}
+
+// 发送一次GET请求
+func (h *httpGetter) get(ctx context.Context, group string, key string) ([]byte, error) {
+	defer h.observe(time.Now())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url(group, key), nil)
+	if err != nil {
+		return nil, err
+	}
+	setFallbackHeader(ctx, req)
+	// 发送GET请求获取返回值，并转换为 []bytes 类型。
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusNotFound && res.Header.Get(notFoundHeader) != "" {
+		return nil, ErrNotFound
+	}
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned: %v", res.Status)
+		return nil, &statusError{code: res.StatusCode, status: res.Status}
 	}
 
 	bytes, err := ioutil.ReadAll(res.Body)
@@ -149,4 +621,158 @@ func (h *httpGetter) Get(group string, key string) ([]byte, error) {
 	return bytes, nil
 }
 
+// 实现PeerBatchGetter接口的GetMulti方法，向 <baseURL><group>/ 发送POST请求，请求体为JSON格式的key列表
+func (h *httpGetter) GetMulti(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	var values map[string][]byte
+	err := h.retry(ctx, func(ctx context.Context) (err error) {
+		values, err = h.getMulti(ctx, group, keys)
+		return err
+	})
+	return values, err
+}
+
+// 发送一次批量获取的POST请求
+func (h *httpGetter) getMulti(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	defer h.observe(time.Now())
+	body, err := json.Marshal(keys)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.baseURL+url.QueryEscape(group)+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setFallbackHeader(ctx, req)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, &statusError{code: res.StatusCode, status: res.Status}
+	}
+
+	var values map[string][]byte
+	if err := json.NewDecoder(res.Body).Decode(&values); err != nil {
+		return nil, fmt.Errorf("decoding response body: %v", err)
+	}
+	return values, nil
+}
+
+// 实现PeerRemover接口的Remove方法，向远程节点发送DELETE请求
+func (h *httpGetter) Remove(group string, key string) error {
+	return h.retry(context.Background(), func(ctx context.Context) error {
+		return h.do(ctx, http.MethodDelete, group, key, nil, nil)
+	})
+}
+
+// 实现PeerSetter接口的Set方法，向远程节点发送PUT请求，请求体为新值
+func (h *httpGetter) Set(group string, key string, value []byte) error {
+	return h.retry(context.Background(), func(ctx context.Context) error {
+		return h.do(ctx, http.MethodPut, group, key, bytes.NewReader(value), nil)
+	})
+}
+
+// 实现PeerReplicator接口的Replicate方法，发送带有副本请求头的PUT请求
+func (h *httpGetter) Replicate(group string, key string, value []byte) error {
+	header := http.Header{replicaHeader: {"1"}}
+	return h.retry(context.Background(), func(ctx context.Context) error {
+		return h.do(ctx, http.MethodPut, group, key, bytes.NewReader(value), header)
+	})
+}
+
+// 发送不需要读取响应体的请求
+func (h *httpGetter) do(ctx context.Context, method string, group string, key string, body io.Reader, header http.Header) error {
+	defer h.observe(time.Now())
+	req, err := http.NewRequestWithContext(ctx, method, h.url(group, key), body)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return &statusError{code: res.StatusCode, status: res.Status}
+	}
+	return nil
+}
+
+// 代替其他节点加载时，通知远程节点直接从本地加载
+func setFallbackHeader(ctx context.Context, req *http.Request) {
+	if isLocalLoad(ctx) {
+		req.Header.Set(fallbackHeader, "1")
+	}
+}
+
+// peerChain 是key在哈希环上依次经过的远程节点，读取时从第一个节点开始尝试，
+// 后面的节点代为从数据源加载；写入和删除只发给key所属的节点，即使它的熔断器已断开
+type peerChain struct {
+	owner   *httpGetter
+	getters []*httpGetter
+}
+
+func (c *peerChain) Get(group string, key string) ([]byte, error) {
+	return c.GetContext(context.Background(), group, key)
+}
+
+func (c *peerChain) GetContext(ctx context.Context, group string, key string) (bytes []byte, err error) {
+	for i, h := range c.getters {
+		if i > 0 {
+			log.Printf("[GeeCache] Peer %s failed: %v, falling back to %s", c.getters[i-1].baseURL, err, h.baseURL)
+		}
+		bytes, err = h.GetContext(c.localLoad(ctx, h), group, key)
+		if err == nil || errors.Is(err, ErrNotFound) || ctx.Err() != nil {
+			return
+		}
+	}
+	return
+}
+
+func (c *peerChain) GetMulti(ctx context.Context, group string, keys []string) (values map[string][]byte, err error) {
+	for i, h := range c.getters {
+		if i > 0 {
+			log.Printf("[GeeCache] Peer %s failed: %v, falling back to %s", c.getters[i-1].baseURL, err, h.baseURL)
+		}
+		values, err = h.GetMulti(c.localLoad(ctx, h), group, keys)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+	}
+	return
+}
+
+// 发给key所属节点以外的节点时，通知它直接从本地加载，否则它会再转发给所属节点。
+// 所属节点的熔断器断开而被跳过时，第一个节点就需要标记
+func (c *peerChain) localLoad(ctx context.Context, h *httpGetter) context.Context {
+	if h != c.owner {
+		return withLocalLoad(ctx)
+	}
+	return ctx
+}
+
+func (c *peerChain) Remove(group string, key string) error {
+	return c.owner.Remove(group, key)
+}
+
+func (c *peerChain) Set(group string, key string, value []byte) error {
+	return c.owner.Set(group, key, value)
+}
+
 var _ PeerGetter = (*httpGetter)(nil)
+var _ ContextPeerGetter = (*httpGetter)(nil)
+var _ PeerRemover = (*httpGetter)(nil)
+var _ PeerSetter = (*httpGetter)(nil)
+var _ PeerBatchGetter = (*httpGetter)(nil)
+var _ PeerReplicator = (*httpGetter)(nil)
+var _ ContextPeerGetter = (*peerChain)(nil)
+var _ PeerBatchGetter = (*peerChain)(nil)
+var _ PeerRemover = (*peerChain)(nil)
+var _ PeerSetter = (*peerChain)(nil)
@@ -0,0 +1,374 @@
+package geecache
+
+import (
+	"Learning_Code/geecache/consistenthash"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//测试通过HTTP协议获取和删除远程节点的缓存
+func TestHTTPGetAndRemove(t *testing.T) {
+	loads := 0
+	NewGroup("http", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+
+	peers := NewHTTPPool("")
+	srv := httptest.NewServer(peers)
+	defer srv.Close()
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath}
+
+	for i := 0; i < 2; i++ {
+		if b, err := h.Get("http", "Tom"); err != nil || string(b) != "Tom" {
+			t.Fatalf("failed to get Tom from peer: %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("Tom should be loaded once, got %d", loads)
+	}
+
+	//删除后再次获取需要重新加载
+	if err := h.Remove("http", "Tom"); err != nil {
+		t.Fatalf("failed to remove Tom from peer: %v", err)
+	}
+	if _, err := h.Get("http", "Tom"); err != nil || loads != 2 {
+		t.Fatalf("Tom should be reloaded after remove, loads = %d", loads)
+	}
+}
+
+//测试 /metrics 以Prometheus文本格式导出统计数据
+func TestMetrics(t *testing.T) {
+	NewGroup("metrics", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+
+	peers := NewHTTPPool("")
+	srv := httptest.NewServer(peers)
+	defer srv.Close()
+	peers.Set(srv.URL)
+	if _, err := peers.httpGetters[srv.URL].Get("metrics", "Tom"); err != nil {
+		t.Fatalf("failed to get Tom from peer: %v", err)
+	}
+
+	res, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("failed to scrape metrics: %v", err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+
+	expect := []string{
+		"# TYPE geecache_gets_total counter",
+		`geecache_gets_total{group="metrics"} 1`,
+		`geecache_local_loads_total{group="metrics"} 1`,
+		`geecache_server_requests_total{group="metrics"} 1`,
+		`geecache_cache_items{group="metrics"} 1`,
+		"geecache_ring_peers 1",
+		"geecache_ring_virtual_nodes 50",
+		`geecache_ring_share{peer="` + srv.URL + `"} 1`,
+		`geecache_peer_request_duration_seconds_bucket{peer="` + srv.URL + `",le="+Inf"} 1`,
+		`geecache_peer_request_duration_seconds_count{peer="` + srv.URL + `"} 1`,
+	}
+	for _, line := range expect {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("metrics should contain %q, got:\n%s", line, body)
+		}
+	}
+}
+
+//测试httpGetter在ctx结束时取消请求
+func TestHTTPGetContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := h.GetContext(ctx, "scores", "Tom"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+}
+
+//测试请求超时和失败后的重试，4xx不重试
+func TestHTTPRetries(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/missing"):
+			http.Error(w, "no such group", http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "/slow") && n == 1:
+			time.Sleep(50 * time.Millisecond)
+		case n <= 2:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("630"))
+		}
+	}))
+	defer srv.Close()
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath, timeout: 20 * time.Millisecond, retries: 2, backoff: time.Millisecond}
+
+	if b, err := h.Get("scores", "Tom"); err != nil || string(b) != "630" || atomic.LoadInt32(&requests) != 3 {
+		t.Fatalf("expect success after 2 retries, got %q, %v after %d requests", b, err, requests)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	if _, err := h.Get("scores", "missing"); err == nil || atomic.LoadInt32(&requests) != 1 {
+		t.Fatalf("4xx should not be retried, got %v after %d requests", err, requests)
+	}
+
+	//第一次请求超时，重试两次，第二次仍返回5xx
+	atomic.StoreInt32(&requests, 0)
+	if b, err := h.Get("scores", "slow"); err != nil || string(b) != "630" || atomic.LoadInt32(&requests) != 3 {
+		t.Fatalf("expect success after a timeout, got %q, %v after %d requests", b, err, requests)
+	}
+}
+
+//测试主节点不可用时由哈希环上后面的节点代为加载
+func TestHTTPPeerFallback(t *testing.T) {
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer dead.Close()
+	loads := 0
+	gee := NewGroup("fallback", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	peer := &fakePeer{}
+	gee.RegisterPeers(peer)
+	live := httptest.NewServer(NewHTTPPool(""))
+	defer live.Close()
+
+	chain := &peerChain{getters: []*httpGetter{
+		{baseURL: dead.URL + defaultBasePath},
+		{baseURL: live.URL + defaultBasePath},
+	}}
+	if b, err := chain.Get("fallback", "Tom"); err != nil || string(b) != "Tom" {
+		t.Fatalf("expect Tom from the fallback peer, got %q, %v", b, err)
+	}
+	//代为加载的节点直接从本地加载，不再访问自己的远程节点
+	if loads != 1 || peer.gets != 0 {
+		t.Fatalf("fallback peer should load locally, loads = %d, peer gets = %d", loads, peer.gets)
+	}
+
+	//相同节点序列的key使用同一个peerChain
+	pool := NewHTTPPool("a", WithPeerFallback(1))
+	pool.Set("a", "b", "c")
+	for _, key := range []string{"Tom", "Jack", "Sam", "Tom"} {
+		p1, ok1 := pool.PickPeer(key)
+		p2, ok2 := pool.PickPeer(key)
+		if ok1 != ok2 || p1 != p2 {
+			t.Fatalf("PickPeer(%s) should return the same peer", key)
+		}
+	}
+}
+
+//测试增删节点时保留已有的httpGetter，只有受影响的key改变所属节点
+func TestHTTPPoolMembership(t *testing.T) {
+	pool := NewHTTPPool("http://a")
+	pool.Set("http://a", "http://b", "http://c")
+	b := pool.httpGetters["http://b"]
+
+	owner := func(key string) string {
+		if peer, ok := pool.PickPeer(key); ok {
+			return peer.(*httpGetter).baseURL
+		}
+		return "self"
+	}
+	before := make(map[string]string)
+	for i := 0; i < 500; i++ {
+		key := strconv.Itoa(i)
+		before[key] = owner(key)
+	}
+
+	pool.AddPeers("http://d")
+	pool.RemovePeers("http://c")
+	if pool.httpGetters["http://b"] != b || len(pool.httpGetters) != 3 {
+		t.Fatalf("existing httpGetter should be kept")
+	}
+	d := "http://d" + defaultBasePath
+	for key, was := range before {
+		now := owner(key)
+		if now != was && now != d && was != "http://c"+defaultBasePath {
+			t.Fatalf("key %s moved from %s to %s", key, was, now)
+		}
+	}
+
+	//Set只增删有变化的节点
+	pool.Set("http://a", "http://b", "http://c")
+	if pool.httpGetters["http://b"] != b || pool.httpGetters["http://d"] != nil {
+		t.Fatalf("Set should keep http://b and drop http://d")
+	}
+	for key, was := range before {
+		if now := owner(key); now != was {
+			t.Fatalf("key %s should return to %s, got %s", key, was, now)
+		}
+	}
+}
+
+//测试带权重的节点，调整权重时保留httpGetter
+func TestHTTPPoolSetWeighted(t *testing.T) {
+	pool := NewHTTPPool("http://a")
+	pool.SetWeighted(map[string]int{"http://a": 1, "http://b": 2})
+	b := pool.httpGetters["http://b"]
+	if pool.peers.Len() != 3*defaultReplicas {
+		t.Fatalf("expect %d virtual nodes, got %d", 3*defaultReplicas, pool.peers.Len())
+	}
+
+	pool.SetWeighted(map[string]int{"http://a": 1, "http://b": 4, "http://c": 0})
+	if pool.peers.Len() != 5*defaultReplicas || pool.httpGetters["http://b"] != b || pool.httpGetters["http://c"] != nil {
+		t.Fatalf("unexpected ring after changing weights: %d virtual nodes", pool.peers.Len())
+	}
+
+	pool.Set("http://a", "http://b")
+	if pool.peers.Len() != 2*defaultReplicas || pool.httpGetters["http://b"] != b {
+		t.Fatalf("Set should reset weights to 1")
+	}
+}
+
+//测试通过WithSelector替换选择节点的算法
+func TestHTTPPoolSelector(t *testing.T) {
+	pool := NewHTTPPool("http://a", WithSelector(func() consistenthash.Selector {
+		return consistenthash.NewJump(nil)
+	}))
+	pool.Set("http://a", "http://b", "http://c")
+	if _, ok := pool.peers.(*consistenthash.Jump); !ok || pool.peers.Len() != 3 {
+		t.Fatalf("pool should use the jump hash selector")
+	}
+	for _, key := range []string{"Tom", "Jack", "Sam"} {
+		peer, ok := pool.PickPeer(key)
+		if owner := pool.peers.Get(key); ok != (owner != "http://a") || ok && peer != pool.httpGetters[owner] {
+			t.Fatalf("PickPeer(%s) should follow the selector", key)
+		}
+	}
+}
+
+//测试各节点以不同的顺序和增删历史设置节点列表时，对key的归属一致
+func TestHTTPPoolSelectorOrder(t *testing.T) {
+	peers := []string{"http://a", "http://b", "http://c", "http://d", "http://e"}
+	newJump := WithSelector(func() consistenthash.Selector { return consistenthash.NewJump(nil) })
+
+	a := NewHTTPPool("http://a", newJump)
+	a.Set(peers...)
+	b := NewHTTPPool("http://a", newJump)
+	b.AddPeers("http://e", "http://c")
+	b.SetWeighted(map[string]int{"http://e": 1, "http://d": 1, "http://c": 1, "http://b": 1, "http://a": 1})
+	b.RemovePeers("http://c")
+	b.AddPeers("http://c")
+	for i := 0; i < 1000; i++ {
+		key := "key" + strconv.Itoa(i)
+		if a.peers.Get(key) != b.peers.Get(key) {
+			t.Fatalf("%s goes to %s and %s with different add orders", key, a.peers.Get(key), b.peers.Get(key))
+		}
+	}
+}
+
+//测试有界负载模式下httpGetter在请求开始和结束时报告节点的负载
+func TestHTTPPoolBoundedLoad(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.Write([]byte("630"))
+	}))
+	defer srv.Close()
+
+	pool := NewHTTPPool("", WithBoundedLoad(0.25))
+	pool.Set(srv.URL)
+	ring := pool.peers.(*consistenthash.Map)
+	peer, ok := pool.PickPeer("Tom")
+	if !ok {
+		t.Fatalf("failed to pick peer")
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := peer.Get("scores", "Tom")
+		done <- err
+	}()
+	<-started
+	if load := ring.Load(srv.URL); load != 1 {
+		t.Fatalf("expect load 1 while the request is in flight, got %d", load)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("failed to get Tom: %v", err)
+	}
+	if load := ring.Load(srv.URL); load != 0 {
+		t.Fatalf("expect load 0 after the request finished, got %d", load)
+	}
+}
+
+//测试有界负载模式下分流到其他节点的读请求由该节点从本地加载，不会再转发回负载过高的所属节点；
+//写入、删除和副本仍然使用所属节点
+func TestHTTPPoolBoundedLoadSpill(t *testing.T) {
+	var ownerHits int32
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&ownerHits, 1)
+		w.Write([]byte("owner"))
+	}))
+	defer owner.Close()
+
+	var spillPool *HTTPPool
+	spill := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spillPool.ServeHTTP(w, r)
+	}))
+	defer spill.Close()
+	spillPool = NewHTTPPool(spill.URL, WithBoundedLoad(0))
+	spillPool.Set(owner.URL, spill.URL)
+	NewGroup("bounded-spill", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte("local-" + key), nil
+		})).RegisterPeers(spillPool)
+
+	client := NewHTTPPool("", WithBoundedLoad(0))
+	client.Set(owner.URL, spill.URL)
+	ring := client.peers.(*consistenthash.Map)
+	key := ""
+	for i := 0; key == ""; i++ {
+		if k := "key" + strconv.Itoa(i); ring.Owner(k) == owner.URL {
+			key = k
+		}
+	}
+	//所属节点已有一个请求在处理，epsilon为0时新的请求分流到另一个节点
+	ring.Inc(owner.URL)
+	defer ring.Done(owner.URL)
+	if got := ring.Get(key); got != spill.URL {
+		t.Fatalf("%s should spill over to %s, got %s", key, spill.URL, got)
+	}
+
+	peer, ok := client.PickPeer(key)
+	if !ok {
+		t.Fatalf("failed to pick peer")
+	}
+	if b, err := peer.Get("bounded-spill", key); err != nil || string(b) != "local-"+key || atomic.LoadInt32(&ownerHits) != 0 {
+		t.Fatalf("spill-over node should load %s locally, got %q, %v, owner hits = %d", key, b, err, ownerHits)
+	}
+
+	if p, ok := client.PickOwner(key); !ok || p != client.httpGetters[owner.URL] {
+		t.Fatalf("writes should still go to the owner under load")
+	}
+	if peers := client.PickPeers(key, 2); peers[0] != client.httpGetters[owner.URL] {
+		t.Fatalf("the primary replica should not change under load")
+	}
+}
@@ -0,0 +1,204 @@
+package lfu
+
+import (
+	"Learning_Code/geecache/lru"
+	"container/heap"
+	"sort"
+	"time"
+)
+
+// LFU cache，淘汰访问次数最少的条目，访问次数相同时淘汰最久未访问的条目，对并发访问不安全
+type Cache struct {
+	maxBytes int64 // 最大容量
+	nBytes   int64 // 已用容量
+	tick     int64 // 逻辑时钟，每次访问递增，用于区分访问次数相同的条目
+	queue    queue // 按(访问次数, 最近访问时间)排序的小根堆，堆顶即为淘汰对象
+	cache    map[string]*entry
+	// 可选属性，在删除条目时执行的回调函数
+	OnEvicted func(key string, value Value)
+}
+
+// 与lru包共用Value定义，使不同淘汰策略可以互相替换
+type Value = lru.Value
+
+// 堆中存储的条目
+type entry struct {
+	key    string
+	value  Value
+	expire time.Time // 过期时间，零值表示永不过期
+	freq   int       // 访问次数
+	tick   int64     // 最近一次访问的逻辑时间
+	index  int       // 在堆中的下标，heap.Fix需要
+}
+
+// 判断条目在now时刻是否已过期
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && now.After(e.expire)
+}
+
+// 用于实例化的New()函数
+func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		cache:     make(map[string]*entry),
+		OnEvicted: onEvicted,
+	}
+}
+
+// 查找功能，命中时访问次数加1
+func (c *Cache) Get(key string) (value Value, ok bool) {
+	e, ok := c.cache[key]
+	if !ok {
+		return
+	}
+	// 惰性过期：查找时发现条目已过期，直接删除并视为未命中
+	if e.expired(time.Now()) {
+		c.removeEntry(e)
+		return nil, false
+	}
+	c.touch(e)
+	return e.value, true
+}
+
+// 新增/修改，条目永不过期
+func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// 新增/修改，条目在expire时刻之后过期，expire为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
+	if e, ok := c.cache[key]; ok {
+		// 修改视为一次访问
+		c.nBytes += int64(value.Len()) - int64(e.value.Len())
+		e.value = value
+		e.expire = expire
+		c.touch(e)
+	} else {
+		c.tick++
+		e := &entry{key: key, value: value, expire: expire, freq: 1, tick: c.tick}
+		heap.Push(&c.queue, e)
+		c.cache[key] = e
+		c.nBytes += int64(len(key)) + int64(value.Len())
+	}
+
+	// 如果超过Cache最大容量，需要删除条目
+	for c.maxBytes != 0 && c.maxBytes < c.nBytes {
+		c.RemoveLeastFrequent()
+	}
+}
+
+// 缓存淘汰，删除访问次数最少的条目
+func (c *Cache) RemoveLeastFrequent() {
+	if c.queue.Len() > 0 {
+		c.removeEntry(c.queue[0])
+	}
+}
+
+// 删除key对应的条目，key不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if e, ok := c.cache[key]; ok {
+		c.removeEntry(e)
+	}
+}
+
+// 清理所有已过期的条目，供后台清理协程定期调用
+func (c *Cache) RemoveExpired() {
+	now := time.Now()
+	for _, e := range c.cache {
+		if e.expired(now) {
+			c.removeEntry(e)
+		}
+	}
+}
+
+// 按淘汰顺序（访问次数从少到多，次数相同时从旧到新）遍历未过期的条目，fn返回false时停止遍历
+func (c *Cache) Range(fn func(key string, value Value, expire time.Time) bool) {
+	now := time.Now()
+	list := make(queue, len(c.queue))
+	copy(list, c.queue)
+	sort.Slice(list, list.Less)
+	for _, e := range list {
+		if e.expired(now) {
+			continue
+		}
+		if !fn(e.key, e.value, e.expire) {
+			return
+		}
+	}
+}
+
+// 返回加入key/value时首先会被淘汰的条目的key，不需要淘汰或key已存在时ok为false，供准入策略使用
+func (c *Cache) Victim(key string, value Value) (victim string, ok bool) {
+	if _, exist := c.cache[key]; exist || c.maxBytes == 0 {
+		return
+	}
+	if c.nBytes+int64(len(key))+int64(value.Len()) <= c.maxBytes {
+		return
+	}
+	// 新条目的访问次数为1且最近访问时间最新，不会排在已有条目之前
+	if c.queue.Len() > 0 {
+		return c.queue[0].key, true
+	}
+	return
+}
+
+// 返回已用容量
+func (c *Cache) Bytes() int64 {
+	return c.nBytes
+}
+
+// 返回已经插入的条目数量
+func (c *Cache) Len() int {
+	return c.queue.Len()
+}
+
+// 更新条目的访问次数与访问时间，并调整其在堆中的位置
+func (c *Cache) touch(e *entry) {
+	c.tick++
+	e.freq++
+	e.tick = c.tick
+	heap.Fix(&c.queue, e.index)
+}
+
+// 从堆和字典中删除条目，并更新已用容量
+func (c *Cache) removeEntry(e *entry) {
+	heap.Remove(&c.queue, e.index)
+	delete(c.cache, e.key)
+	c.nBytes -= int64(len(e.key)) + int64(e.value.Len())
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// queue实现heap.Interface
+type queue []*entry
+
+func (q queue) Len() int { return len(q) }
+
+func (q queue) Less(i, j int) bool {
+	if q[i].freq == q[j].freq {
+		return q[i].tick < q[j].tick
+	}
+	return q[i].freq < q[j].freq
+}
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *queue) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *queue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return e
+}
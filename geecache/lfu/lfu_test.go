@@ -0,0 +1,99 @@
+package lfu
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+func TestCache_Get(t *testing.T) {
+	lfu := New(int64(0), nil)
+	lfu.Add("key1", String("1234"))
+	if v, ok := lfu.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := lfu.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+func TestRemoveLeastFrequent(t *testing.T) {
+	keys := make([]string, 0)
+	callback := func(key string, value Value) {
+		keys = append(keys, key)
+	}
+	// 容量刚好容纳3个条目
+	lfu := New(int64(12), callback)
+	lfu.Add("k1", String("v1"))
+	lfu.Add("k2", String("v2"))
+	lfu.Add("k3", String("v3"))
+	// k1访问两次、k3访问一次，k2访问次数最少
+	lfu.Get("k1")
+	lfu.Get("k1")
+	lfu.Get("k3")
+	// k2与新加入的k4访问次数相同，k2更久未访问，因此淘汰k2
+	lfu.Add("k4", String("v4"))
+	// k4与新加入的k5访问次数最少，淘汰更久未访问的k4
+	lfu.Add("k5", String("v5"))
+
+	expect := []string{"k2", "k4"}
+	if !reflect.DeepEqual(expect, keys) || lfu.Len() != 3 {
+		t.Fatalf("RemoveLeastFrequent failed, expect keys equals to %s, got %s", expect, keys)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	lfu := New(int64(0), nil)
+	lfu.AddWithExpire("key1", String("1"), time.Now().Add(-time.Second))
+	lfu.AddWithExpire("key2", String("2"), time.Now().Add(-time.Second))
+	lfu.Add("key3", String("3"))
+
+	if _, ok := lfu.Get("key1"); ok || lfu.Len() != 2 {
+		t.Fatalf("lazy expire key1 failed")
+	}
+	lfu.RemoveExpired()
+	if _, ok := lfu.Get("key3"); !ok || lfu.Len() != 1 {
+		t.Fatalf("RemoveExpired failed")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	lfu := New(int64(0), nil)
+	lfu.Add("key1", String("1234"))
+	lfu.Add("key2", String("5678"))
+	lfu.Remove("key1")
+	lfu.Remove("key3")
+	if _, ok := lfu.Get("key1"); ok || lfu.Len() != 1 {
+		t.Fatalf("Remove key1 failed")
+	}
+}
+
+func TestVictim(t *testing.T) {
+	keys := make([]string, 0)
+	callback := func(key string, value Value) {
+		keys = append(keys, key)
+	}
+	lfu := New(int64(12), callback)
+	lfu.Add("k1", String("v1"))
+	lfu.Add("k2", String("v2"))
+	if _, ok := lfu.Victim("k3", String("v3")); ok {
+		t.Fatalf("no victim expected while there is room")
+	}
+	lfu.Add("k3", String("v3"))
+	lfu.Get("k1")
+	if _, ok := lfu.Victim("k1", String("v1")); ok {
+		t.Fatalf("no victim expected when updating an existing key")
+	}
+	// 预测的淘汰对象与实际淘汰的条目一致
+	victim, ok := lfu.Victim("k4", String("v4"))
+	lfu.Add("k4", String("v4"))
+	if !ok || victim != "k2" || len(keys) != 1 || keys[0] != victim {
+		t.Fatalf("victim should be k2, got %s, evicted %s", victim, keys)
+	}
+}
@@ -1,6 +1,9 @@
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"time"
+)
 
 //参考：https://geektutu.com/post/geecache-day1.html
 
@@ -17,8 +20,14 @@ type Cache struct {
 
 //双向链表中所存的条目，字典中有了kv映射仍要在链表中存key的原因是：淘汰节点时需要用key从字典中删除对应的映射
 type entry struct {
-	key   string
-	value Value
+	key    string
+	value  Value
+	expire time.Time // 过期时间，零值表示永不过期
+}
+
+//判断条目在now时刻是否已过期
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && now.After(e.expire)
 }
 
 //为了增加通用性，所存的Value可以为任意实现了Len()方法的类型
@@ -44,6 +53,11 @@ func (c *Cache) Get(key string) (value Value, ok bool) {
 		c.ll.MoveToFront(elem)
 		//elem是一个*List.Element，将其强转为*entry
 		kv := elem.Value.(*entry)
+		//惰性过期：查找时发现条目已过期，直接删除并视为未命中
+		if kv.expired(time.Now()) {
+			c.removeElement(elem)
+			return nil, false
+		}
 		return kv.value, true
 	}
 	//查找失败直接返回默认值
@@ -56,23 +70,53 @@ func (c *Cache) Removeoldest() {
 	elem := c.ll.Back()
 	//判空
 	if elem != nil {
-		//从链表中删除该条目
-		c.ll.Remove(elem)
-		//强转为条目类型
-		kv := elem.Value.(*entry)
-		//从字典中删除映射
-		delete(c.cache, kv.key)
-		//已用容量减少删除掉的条目大小
-		c.nBytes -= int64(len(kv.key)) + int64(kv.value.Len()) //value可以为任何格式，因此需要调用Len()
-		//如果Cache有定义删除回调函数，需要返回相应的值
-		if c.OnEvicted != nil {
-			c.OnEvicted(kv.key, kv.value)
+		c.removeElement(elem)
+	}
+}
+
+//删除key对应的条目，key不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if elem, ok := c.cache[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+//清理所有已过期的条目，供后台清理协程定期调用
+func (c *Cache) RemoveExpired() {
+	now := time.Now()
+	//从队尾向队首遍历，删除前先记录前驱节点
+	for elem := c.ll.Back(); elem != nil; {
+		prev := elem.Prev()
+		if elem.Value.(*entry).expired(now) {
+			c.removeElement(elem)
 		}
+		elem = prev
 	}
 }
 
-//新增/修改
+//从链表和字典中删除条目，并更新已用容量
+func (c *Cache) removeElement(elem *list.Element) {
+	//从链表中删除该条目
+	c.ll.Remove(elem)
+	//强转为条目类型
+	kv := elem.Value.(*entry)
+	//从字典中删除映射
+	delete(c.cache, kv.key)
+	//已用容量减少删除掉的条目大小
+	c.nBytes -= int64(len(kv.key)) + int64(kv.value.Len()) //value可以为任何格式，因此需要调用Len()
+	//如果Cache有定义删除回调函数，需要返回相应的值
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
+
+//新增/修改，条目永不过期
 func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+//新增/修改，条目在expire时刻之后过期，expire为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
 	//如果字典中已存在该key，执行修改
 	if elem, ok := c.cache[key]; ok {
 		//将该节点移动到Front
@@ -80,12 +124,13 @@ func (c *Cache) Add(key string, value Value) {
 		//强转类型
 		kv := elem.Value.(*entry)
 		//更新已用内存
-		c.nBytes += int64(kv.value.Len()) - int64(value.Len())
+		c.nBytes += int64(value.Len()) - int64(kv.value.Len())
 		//由于kv是*entry类型，因此可以修改底层的value
 		kv.value = value
+		kv.expire = expire
 	} else { //字典中不存在该key，执行新增
 		//直接将新建的条目加入到Front
-		elem := c.ll.PushFront(&entry{key, value})
+		elem := c.ll.PushFront(&entry{key, value, expire})
 		//将新节点与字典映射
 		c.cache[key] = elem
 		//更新已用内存
@@ -98,6 +143,40 @@ func (c *Cache) Add(key string, value Value) {
 	}
 }
 
+//按从最久未使用到最近使用的顺序遍历未过期的条目，fn返回false时停止遍历
+//按遍历顺序依次Add即可恢复相同的LRU顺序
+func (c *Cache) Range(fn func(key string, value Value, expire time.Time) bool) {
+	now := time.Now()
+	for elem := c.ll.Back(); elem != nil; elem = elem.Prev() {
+		kv := elem.Value.(*entry)
+		if kv.expired(now) {
+			continue
+		}
+		if !fn(kv.key, kv.value, kv.expire) {
+			return
+		}
+	}
+}
+
+//返回加入key/value时首先会被淘汰的条目的key，不需要淘汰或key已存在时ok为false，供准入策略使用
+func (c *Cache) Victim(key string, value Value) (victim string, ok bool) {
+	if _, exist := c.cache[key]; exist || c.maxBytes == 0 {
+		return
+	}
+	if c.nBytes+int64(len(key))+int64(value.Len()) <= c.maxBytes {
+		return
+	}
+	if elem := c.ll.Back(); elem != nil {
+		return elem.Value.(*entry).key, true
+	}
+	return
+}
+
+//返回已用容量
+func (c *Cache) Bytes() int64 {
+	return c.nBytes
+}
+
 //为了方便测试，重写Cache的Len()，使其返回已经插入的条目数量
 func (c *Cache) Len() int {
 	return c.ll.Len()
@@ -3,6 +3,7 @@ package lru
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -53,3 +54,81 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
 	}
 }
+
+func TestExpire(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.AddWithExpire("key1", String("1234"), time.Now().Add(-time.Second))
+	lru.AddWithExpire("key2", String("5678"), time.Now().Add(time.Hour))
+	lru.Add("key3", String("90"))
+
+	//key1已过期，Get时应被惰性删除
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 2 {
+		t.Fatalf("lazy expire key1 failed")
+	}
+	if v, ok := lru.Get("key2"); !ok || string(v.(String)) != "5678" {
+		t.Fatalf("cache hit key2=5678 failed")
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	keys := make([]string, 0)
+	callback := func(key string, value Value) {
+		keys = append(keys, key)
+	}
+	lru := New(int64(0), callback)
+	lru.AddWithExpire("key1", String("1"), time.Now().Add(-time.Second))
+	lru.Add("key2", String("2"))
+	lru.AddWithExpire("key3", String("3"), time.Now().Add(-time.Second))
+	lru.RemoveExpired()
+
+	expect := []string{"key1", "key3"}
+	if !reflect.DeepEqual(expect, keys) || lru.Len() != 1 {
+		t.Fatalf("RemoveExpired failed, expect keys equals to %s, got %s", expect, keys)
+	}
+}
+
+func TestVictim(t *testing.T) {
+	lru := New(int64(12), nil)
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	if _, ok := lru.Victim("k3", String("v3")); ok {
+		t.Fatalf("no victim expected while there is room")
+	}
+	lru.Add("k3", String("v3"))
+	if victim, ok := lru.Victim("k4", String("v4")); !ok || victim != "k1" {
+		t.Fatalf("victim should be k1, got %s", victim)
+	}
+	if _, ok := lru.Victim("k2", String("v2")); ok {
+		t.Fatalf("no victim expected when updating an existing key")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("key1", String("1234"))
+	lru.Add("key2", String("5678"))
+	lru.Remove("key1")
+	lru.Remove("key3")
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 1 || lru.nBytes != int64(len("key25678")) {
+		t.Fatalf("Remove key1 failed")
+	}
+}
+
+func TestRange(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.AddWithExpire("k3", String("v3"), time.Now().Add(-time.Second))
+	lru.Add("k4", String("v4"))
+	lru.Get("k1")
+
+	keys := make([]string, 0)
+	lru.Range(func(key string, value Value, expire time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+	expect := []string{"k2", "k4", "k1"}
+	if !reflect.DeepEqual(expect, keys) {
+		t.Fatalf("Range failed, expect keys equals to %s, got %s", expect, keys)
+	}
+}
@@ -0,0 +1,150 @@
+package geecache
+
+import (
+	"Learning_Code/geecache/consistenthash"
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 以Prometheus文本格式导出监控指标，只依赖标准库
+// 参考：https://prometheus.io/docs/instrumenting/exposition_formats/
+
+const defaultMetricsPath = "/metrics"
+
+// 请求耗时直方图的桶上界，单位为秒
+var latencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// histogram 记录请求耗时的分布，可以并发使用
+type histogram struct {
+	counts []int64 // 每个桶的计数（非累积），最后一个为+Inf桶
+	sum    int64   // 耗时总和，单位为纳秒
+	count  int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]int64, len(latencyBuckets)+1)}
+}
+
+// 记录一次耗时
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBuckets) && d.Seconds() > latencyBuckets[i] {
+		i++
+	}
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+	atomic.AddInt64(&h.count, 1)
+}
+
+// 处理 /metrics 请求，导出所有Group的统计数据、各远程节点的请求耗时以及哈希环的大小
+func (p *HTTPPool) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	writeGroupMetrics(bw, allGroups())
+	p.writePeerMetrics(bw)
+	bw.Flush()
+}
+
+// 每个Group导出的指标：名称、类型、说明以及从快照中取值的函数
+var groupMetrics = []struct {
+	name, typ, help string
+	value           func(Stats) int64
+}{
+	{"geecache_gets_total", "counter", "Get requests, including requests from peers.", func(s Stats) int64 { return s.Gets }},
+	{"geecache_cache_hits_total", "counter", "Get requests served from the cache.", func(s Stats) int64 { return s.CacheHits }},
+	{"geecache_negative_hits_total", "counter", "Get requests answered from the negative cache.", func(s Stats) int64 { return s.NegativeHits }},
+	{"geecache_loads_total", "counter", "Get requests that missed the cache.", func(s Stats) int64 { return s.Loads }},
+	{"geecache_loads_deduped_total", "counter", "Loads left after singleflight deduplication.", func(s Stats) int64 { return s.LoadsDeduped }},
+	{"geecache_loads_shared_total", "counter", "Loads whose result was shared with concurrent callers.", func(s Stats) int64 { return s.LoadsShared }},
+	{"geecache_refreshes_total", "counter", "Background refreshes started for entries close to expiry.", func(s Stats) int64 { return s.Refreshes }},
+	{"geecache_peer_loads_total", "counter", "Values loaded from remote peers.", func(s Stats) int64 { return s.PeerLoads }},
+	{"geecache_peer_errors_total", "counter", "Failed loads from remote peers.", func(s Stats) int64 { return s.PeerErrors }},
+	{"geecache_local_loads_total", "counter", "Values loaded from the local Getter.", func(s Stats) int64 { return s.LocalLoads }},
+	{"geecache_local_load_errors_total", "counter", "Failed loads from the local Getter.", func(s Stats) int64 { return s.LocalLoadErrs }},
+	{"geecache_server_requests_total", "counter", "Requests received from peers.", func(s Stats) int64 { return s.ServerRequests }},
+	{"geecache_write_behind_errors_total", "counter", "Failed asynchronous writes to the Setter.", func(s Stats) int64 { return s.WriteBehindErrs }},
+	{"geecache_cache_items", "gauge", "Items in the main cache.", func(s Stats) int64 { return s.MainCache.Items }},
+	{"geecache_cache_bytes", "gauge", "Bytes used by the main cache.", func(s Stats) int64 { return s.MainCache.Bytes }},
+	{"geecache_cache_evictions_total", "counter", "Items evicted from the main cache.", func(s Stats) int64 { return s.MainCache.Evictions }},
+	{"geecache_hot_cache_items", "gauge", "Items in the hot cache.", func(s Stats) int64 { return s.HotCache.Items }},
+	{"geecache_hot_cache_bytes", "gauge", "Bytes used by the hot cache.", func(s Stats) int64 { return s.HotCache.Bytes }},
+	{"geecache_hot_cache_evictions_total", "counter", "Items evicted from the hot cache.", func(s Stats) int64 { return s.HotCache.Evictions }},
+}
+
+func writeGroupMetrics(w io.Writer, list []*Group) {
+	stats := make([]Stats, len(list))
+	for i, g := range list {
+		stats[i] = g.Stats()
+	}
+	for _, m := range groupMetrics {
+		writeHeader(w, m.name, m.typ, m.help)
+		for i, g := range list {
+			fmt.Fprintf(w, "%s{group=\"%s\"} %d\n", m.name, escapeLabel(g.name), m.value(stats[i]))
+		}
+	}
+}
+
+func (p *HTTPPool) writePeerMetrics(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ringSize := 0
+	if p.peers != nil {
+		ringSize = p.peers.Len()
+	}
+	writeHeader(w, "geecache_ring_peers", "gauge", "Peers in the hash ring.")
+	fmt.Fprintf(w, "geecache_ring_peers %d\n", len(p.httpGetters))
+	writeHeader(w, "geecache_ring_virtual_nodes", "gauge", "Virtual nodes in the hash ring.")
+	fmt.Fprintf(w, "geecache_ring_virtual_nodes %d\n", ringSize)
+	// 哈希环可以报告每个节点负责的哈希空间比例，用于发现虚拟节点分布不均
+	if d, ok := p.peers.(consistenthash.Describer); ok {
+		writeHeader(w, "geecache_ring_share", "gauge", "Share of the hash space owned by each peer.")
+		for _, s := range d.Describe() {
+			fmt.Fprintf(w, "geecache_ring_share{peer=\"%s\"} %s\n", escapeLabel(s.Node), strconv.FormatFloat(s.Share, 'g', -1, 64))
+		}
+	}
+
+	const name = "geecache_peer_request_duration_seconds"
+	writeHeader(w, name, "histogram", "Latency of requests sent to remote peers.")
+	for _, peer := range p.sortedPeers() {
+		h := p.httpGetters[peer].latency
+		label := escapeLabel(peer)
+		var cumulative int64
+		for i, le := range latencyBuckets {
+			cumulative += atomic.LoadInt64(&h.counts[i])
+			fmt.Fprintf(w, "%s_bucket{peer=\"%s\",le=\"%s\"} %d\n", name, label, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		cumulative += atomic.LoadInt64(&h.counts[len(latencyBuckets)])
+		fmt.Fprintf(w, "%s_bucket{peer=\"%s\",le=\"+Inf\"} %d\n", name, label, cumulative)
+		fmt.Fprintf(w, "%s_sum{peer=\"%s\"} %s\n", name, label, strconv.FormatFloat(time.Duration(atomic.LoadInt64(&h.sum)).Seconds(), 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{peer=\"%s\"} %d\n", name, label, atomic.LoadInt64(&h.count))
+	}
+
+	writeHeader(w, "geecache_peer_circuit_state", "gauge", "Circuit breaker state of remote peers: 0 closed, 1 open, 2 half-open.")
+	for _, peer := range p.sortedPeers() {
+		fmt.Fprintf(w, "geecache_peer_circuit_state{peer=\"%s\"} %d\n", escapeLabel(peer), p.httpGetters[peer].breaker.current())
+	}
+	writeHeader(w, "geecache_peer_circuit_opens_total", "counter", "Times the circuit breaker of remote peers opened.")
+	for _, peer := range p.sortedPeers() {
+		var opens int64
+		if b := p.httpGetters[peer].breaker; b != nil {
+			opens = b.opens.Get()
+		}
+		fmt.Fprintf(w, "geecache_peer_circuit_opens_total{peer=\"%s\"} %d\n", escapeLabel(peer), opens)
+	}
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// 转义标签值中的反斜杠、双引号和换行符
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,77 @@
+package geecache
+
+import (
+	"Learning_Code/geecache/lru"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound 表示数据源中不存在key，Getter 可以返回它（或用 %w 包装它）。
+// 远程节点返回 ErrNotFound 时不会再回退到本地加载，启用负缓存后该结果会被缓存
+var ErrNotFound = errors.New("geecache: not found")
+
+// negativeCache 缓存加载失败的结果，在短时间内直接返回错误，避免不存在的key反复访问数据源
+type negativeCache struct {
+	mu       sync.Mutex
+	lru      *lru.Cache
+	maxBytes int64
+	ttl      time.Duration
+}
+
+// 负缓存中的条目，保留原始错误，使 errors.Is 仍然可用
+type negativeEntry struct {
+	err error
+}
+
+func (e negativeEntry) Len() int {
+	return len(e.err.Error())
+}
+
+// WithNegativeCache 为 Group 启用负缓存：数据源返回的错误（包括 ErrNotFound）缓存ttl时长，负缓存最多占用maxBytes
+func WithNegativeCache(ttl time.Duration, maxBytes int64) GroupOption {
+	return func(g *Group) {
+		g.negCache = &negativeCache{maxBytes: maxBytes, ttl: ttl}
+	}
+}
+
+func (c *negativeCache) add(key string, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lru == nil {
+		c.lru = lru.New(c.maxBytes, nil)
+	}
+	c.lru.AddWithExpire(key, negativeEntry{err: err}, time.Now().Add(c.ttl))
+}
+
+func (c *negativeCache) get(key string) (err error, ok bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lru == nil {
+		return
+	}
+	if v, ok := c.lru.Get(key); ok {
+		return v.(negativeEntry).err, true
+	}
+	return
+}
+
+func (c *negativeCache) remove(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lru != nil {
+		c.lru.Remove(key)
+	}
+}
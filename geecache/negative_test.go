@@ -0,0 +1,105 @@
+package geecache
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 测试加载失败的结果被负缓存，过期或删除后重新加载
+func TestNegativeCache(t *testing.T) {
+	loads := 0
+	gee := NewGroup("negative", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}), WithNegativeCache(20*time.Millisecond, 1<<10))
+
+	for i := 0; i < 3; i++ {
+		if _, err := gee.Get("unknow"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expect ErrNotFound, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("unknow should be loaded once, got %d", loads)
+	}
+	if st := gee.Stats(); st.NegativeHits != 2 {
+		t.Fatalf("expect 2 negative hits, got %d", st.NegativeHits)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if gee.Get("unknow"); loads != 2 {
+		t.Fatalf("unknow should be reloaded after negative ttl, loads = %d", loads)
+	}
+
+	//删除key时负缓存一并删除
+	if err := gee.Remove("unknow"); err != nil {
+		t.Fatalf("failed to remove unknow: %v", err)
+	}
+	if gee.Get("unknow"); loads != 3 {
+		t.Fatalf("unknow should be reloaded after remove, loads = %d", loads)
+	}
+}
+
+type notFoundPeer struct {
+	gets int
+}
+
+func (p *notFoundPeer) PickPeer(key string) (PeerGetter, bool) {
+	return p, true
+}
+
+func (p *notFoundPeer) Get(group string, key string) ([]byte, error) {
+	p.gets++
+	return nil, ErrNotFound
+}
+
+// 测试远程节点返回 ErrNotFound 时不回退到本地加载
+func TestPeerNotFound(t *testing.T) {
+	loads := 0
+	gee := NewGroup("negative-peer", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}), WithNegativeCache(time.Minute, 1<<10))
+	peer := &notFoundPeer{}
+	gee.RegisterPeers(peer)
+
+	for i := 0; i < 2; i++ {
+		if _, err := gee.Get("Tom"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expect ErrNotFound, got %v", err)
+		}
+	}
+	if loads != 0 || peer.gets != 1 {
+		t.Fatalf("expect 1 peer get and no local load, got %d and %d", peer.gets, loads)
+	}
+}
+
+// 测试 ErrNotFound 经过HTTP协议传递给对端
+func TestHTTPNotFound(t *testing.T) {
+	NewGroup("http-notfound", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			if key == "broken" {
+				return nil, errors.New("db down")
+			}
+			return nil, ErrNotFound
+		}))
+
+	peers := NewHTTPPool("")
+	srv := httptest.NewServer(peers)
+	defer srv.Close()
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath}
+
+	if _, err := h.Get("http-notfound", "Tom"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect ErrNotFound from peer, got %v", err)
+	}
+	//其他错误和不存在的group不能被当作 ErrNotFound
+	if _, err := h.Get("http-notfound", "broken"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect a plain error, got %v", err)
+	}
+	if _, err := h.Get("no-such-group", "Tom"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expect a plain error for unknown group, got %v", err)
+	}
+}
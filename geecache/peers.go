@@ -1,5 +1,7 @@
 package geecache
 
+import "context"
+
 type PeerPicker interface {
 	// 根据传入的 key 选择相应节点 PeerGetter。
 	PickPeer(key string) (peer PeerGetter, ok bool)
@@ -9,3 +11,74 @@ type PeerGetter interface {
 	// 用于从对应 group 查找缓存值
 	Get(group string, key string) ([]byte, error)
 }
+
+// OwnerPicker 是 PeerPicker 的可选扩展。PickPeer 为了读取可以跳过不可用的节点，
+// 写入和删除则必须发给key真正所属的节点，否则该节点恢复后仍会返回旧值
+type OwnerPicker interface {
+	// 返回key所属的节点，不考虑节点是否可用，key属于本节点时ok为false
+	PickOwner(key string) (peer PeerGetter, ok bool)
+}
+
+// 选择写入和删除key时访问的节点，peers实现了OwnerPicker时使用key真正所属的节点
+func pickOwner(peers PeerPicker, key string) (PeerGetter, bool) {
+	if op, ok := peers.(OwnerPicker); ok {
+		return op.PickOwner(key)
+	}
+	return peers.PickPeer(key)
+}
+
+// PeerRemover 是 PeerGetter 的可选扩展，实现该接口的节点支持删除远程缓存
+type PeerRemover interface {
+	// 用于从对应 group 删除缓存值
+	Remove(group string, key string) error
+}
+
+// PeerSetter 是 PeerGetter 的可选扩展，实现该接口的节点支持写入远程节点
+type PeerSetter interface {
+	// 用于由key所属的节点更新对应 group 的值
+	Set(group string, key string, value []byte) error
+}
+
+// ContextPeerGetter 是 PeerGetter 的可选扩展，访问远程节点时可以感知调用方的截止时间和取消信号
+type ContextPeerGetter interface {
+	// 用于从对应 group 查找缓存值，ctx结束时放弃请求
+	GetContext(ctx context.Context, group string, key string) ([]byte, error)
+}
+
+// 从远程节点获取缓存值，peer实现了ContextPeerGetter时传递ctx
+func getFromPeer(ctx context.Context, peer PeerGetter, group string, key string) ([]byte, error) {
+	if cp, ok := peer.(ContextPeerGetter); ok {
+		return cp.GetContext(ctx, group, key)
+	}
+	return peer.Get(group, key)
+}
+
+// PeerBatchGetter 是 PeerGetter 的可选扩展，实现该接口的节点可以在一次请求中获取多个key
+type PeerBatchGetter interface {
+	// 用于从对应 group 批量查找缓存值，返回的map中不包含数据源中不存在的key
+	GetMulti(ctx context.Context, group string, keys []string) (map[string][]byte, error)
+}
+
+type localLoadKey struct{}
+
+// 标记ctx：本次加载代替不可用的主节点进行，直接从本地数据源加载，不再访问其他节点
+func withLocalLoad(ctx context.Context) context.Context {
+	return context.WithValue(ctx, localLoadKey{}, true)
+}
+
+func isLocalLoad(ctx context.Context) bool {
+	v, _ := ctx.Value(localLoadKey{}).(bool)
+	return v
+}
+
+// MultiPeerPicker 是 PeerPicker 的可选扩展，用于为key选择多个副本节点，见 WithReplication
+type MultiPeerPicker interface {
+	// 按优先顺序返回负责key的最多n个不同节点，第一个为主节点，本节点在列表中用nil表示
+	PickPeers(key string, n int) []PeerGetter
+}
+
+// PeerReplicator 是 PeerGetter 的可选扩展，实现该接口的节点可以接收副本：只更新缓存，不写数据源
+type PeerReplicator interface {
+	// 用于将key的值放入对应 group 的缓存
+	Replicate(group string, key string, value []byte) error
+}
@@ -0,0 +1,53 @@
+package geecache
+
+import (
+	"Learning_Code/geecache/arc"
+	"Learning_Code/geecache/lfu"
+	"Learning_Code/geecache/lru"
+	"Learning_Code/geecache/twoq"
+	"time"
+)
+
+// EvictionPolicy 抽象了缓存的淘汰策略，lru、lfu、arc、twoq 包中的 Cache 都实现了该接口
+// 实现不需要保证并发安全，由 cache 负责加锁
+type EvictionPolicy interface {
+	Get(key string) (value lru.Value, ok bool)
+	Add(key string, value lru.Value)
+	AddWithExpire(key string, value lru.Value, expire time.Time)
+	Remove(key string)
+	RemoveExpired()
+	// 按淘汰顺序（最先被淘汰的在前）遍历未过期的条目，fn返回false时停止遍历
+	Range(fn func(key string, value lru.Value, expire time.Time) bool)
+	Len() int
+	Bytes() int64
+}
+
+// NewPolicyFunc 是淘汰策略的构造函数，maxBytes 为容量上限，onEvicted 在删除条目时回调
+type NewPolicyFunc func(maxBytes int64, onEvicted func(key string, value lru.Value)) EvictionPolicy
+
+// 内置的淘汰策略，通过 WithEvictionPolicy 为 Group 选择
+var (
+	// LRU 淘汰最久未使用的条目，默认策略
+	LRU NewPolicyFunc = func(maxBytes int64, onEvicted func(string, lru.Value)) EvictionPolicy {
+		return lru.New(maxBytes, onEvicted)
+	}
+	// LFU 淘汰访问次数最少的条目，适合热点长期稳定的场景
+	LFU NewPolicyFunc = func(maxBytes int64, onEvicted func(string, lru.Value)) EvictionPolicy {
+		return lfu.New(maxBytes, onEvicted)
+	}
+	// ARC 在最近访问和频繁访问之间自适应调整，兼顾两类访问模式
+	ARC NewPolicyFunc = func(maxBytes int64, onEvicted func(string, lru.Value)) EvictionPolicy {
+		return arc.New(maxBytes, onEvicted)
+	}
+	// TwoQ 只有再次访问的条目才会进入主队列，适合存在大量一次性扫描的场景
+	TwoQ NewPolicyFunc = func(maxBytes int64, onEvicted func(string, lru.Value)) EvictionPolicy {
+		return twoq.New(maxBytes, onEvicted)
+	}
+)
+
+var (
+	_ EvictionPolicy = (*lru.Cache)(nil)
+	_ EvictionPolicy = (*lfu.Cache)(nil)
+	_ EvictionPolicy = (*arc.Cache)(nil)
+	_ EvictionPolicy = (*twoq.Cache)(nil)
+)
@@ -0,0 +1,75 @@
+package geecache
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// refreshAhead 记录 stale-while-revalidate 的配置和正在后台刷新的key
+type refreshAhead struct {
+	window   time.Duration // 距离过期不足window时开始后台刷新
+	maxStale time.Duration // 过期后仍可返回旧值的最长时间
+
+	mu         sync.Mutex
+	refreshing map[string]struct{}
+}
+
+// WithRefreshAhead 为 Group 启用后台刷新：条目距离TTL过期不足window时，Get 立即返回缓存的值，
+// 同时在后台通过singleflight重新加载一次；过期后maxStale时长内仍返回旧值并刷新，超过后才同步加载。
+// 需要与 WithTTL 一起使用
+func WithRefreshAhead(window, maxStale time.Duration) GroupOption {
+	return func(g *Group) {
+		g.refresh = &refreshAhead{window: window, maxStale: maxStale}
+	}
+}
+
+// 计算新条目在缓存中的过期时间，启用后台刷新时额外保留maxStale，零值表示永不过期
+func (g *Group) expireAt() time.Time {
+	if g.ttl <= 0 {
+		return time.Time{}
+	}
+	e := time.Now().Add(g.ttl)
+	if g.refresh != nil {
+		e = e.Add(g.refresh.maxStale)
+	}
+	return e
+}
+
+// 缓存命中的条目接近或已经超过TTL时，启动后台刷新，同一个key同时只有一个刷新协程
+func (g *Group) maybeRefresh(key string, value ByteView) {
+	r := g.refresh
+	if r == nil || value.e.IsZero() {
+		return
+	}
+	// 缓存中的过期时间包含了maxStale，减去后才是TTL到期的时间
+	if time.Until(value.e.Add(-r.maxStale)) > r.window {
+		return
+	}
+
+	r.mu.Lock()
+	if _, ok := r.refreshing[key]; ok {
+		r.mu.Unlock()
+		return
+	}
+	if r.refreshing == nil {
+		r.refreshing = make(map[string]struct{})
+	}
+	r.refreshing[key] = struct{}{}
+	r.mu.Unlock()
+
+	// 后台刷新不是缓存未命中，只计入Refreshes
+	g.stats.Refreshes.Add(1)
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.refreshing, key)
+			r.mu.Unlock()
+		}()
+		// 与前台的加载共用singleflight，加载成功后新值会替换缓存中的旧值
+		if _, err := g.load(context.Background(), key); err != nil {
+			log.Println("[GeeCache] Failed to refresh", key, err)
+		}
+	}()
+}
@@ -0,0 +1,54 @@
+package geecache
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 测试接近过期和过期不久的条目立即返回旧值，并在后台刷新一次
+func TestRefreshAhead(t *testing.T) {
+	var loads int32
+	gee := NewGroup("refresh", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			n := atomic.AddInt32(&loads, 1)
+			if n > 1 {
+				// 后台刷新较慢，不能阻塞Get
+				time.Sleep(20 * time.Millisecond)
+			}
+			return []byte(strconv.Itoa(int(n))), nil
+		}), WithTTL(50*time.Millisecond), WithRefreshAhead(20*time.Millisecond, 100*time.Millisecond))
+
+	if view, _ := gee.Get("Tom"); view.String() != "1" {
+		t.Fatalf("expect first load, got %s", view)
+	}
+
+	//进入刷新窗口，多次Get都立即返回旧值，只启动一次刷新
+	time.Sleep(35 * time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if view, _ := gee.Get("Tom"); view.String() != "1" {
+			t.Fatalf("expect stale value 1, got %s", view)
+		}
+	}
+	if time.Since(start) > 10*time.Millisecond {
+		t.Fatalf("Get should not wait for the refresh")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if n := atomic.LoadInt32(&loads); n != 2 {
+		t.Fatalf("expect exactly one refresh, loads = %d", n)
+	}
+	if view, _ := gee.Get("Tom"); view.String() != "2" {
+		t.Fatalf("expect refreshed value 2, got %s", view)
+	}
+
+	//TTL已过但仍在maxStale内，返回旧值
+	time.Sleep(60 * time.Millisecond)
+	if view, _ := gee.Get("Tom"); view.String() != "2" {
+		t.Fatalf("expect stale value 2 within maxStale, got %s", view)
+	}
+	if st := gee.Stats(); st.Refreshes != 2 || st.Loads != st.Gets-st.CacheHits {
+		t.Fatalf("expect 2 refreshes not counted as loads, got %+v", st)
+	}
+}
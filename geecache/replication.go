@@ -0,0 +1,156 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+)
+
+// WithReplication 设置每个key的副本数量n：peers实现了 MultiPeerPicker 时，key由哈希环上的n个不同节点共同负责。
+// 读取时本节点是主节点则直接从本地加载，是其他副本时先从主节点或其他副本获取，否则从任意一个可用的副本获取，
+// 所有副本都不可用时才回退到数据源，避免同一个key被每个副本各加载一次；写入时由主节点写数据源，
+// 再把新值复制到其他副本；主节点从数据源加载的值也会被抽样复制，越热的key越容易被复制。n小于等于1时不启用
+func WithReplication(n int) GroupOption {
+	return func(g *Group) {
+		g.replicas = n
+	}
+}
+
+// 返回key的副本节点，本节点用nil表示；未启用副本时返回nil
+func (g *Group) pickReplicas(key string) []PeerGetter {
+	if g.replicas <= 1 || g.peers == nil {
+		return nil
+	}
+	picker, ok := g.peers.(MultiPeerPicker)
+	if !ok {
+		return nil
+	}
+	return picker.PickPeers(key, g.replicas)
+}
+
+// 按副本加载key：本节点是主节点时从本地加载；是其他副本时从主节点开始按顺序尝试，
+// 否则从随机的一个副本开始依次尝试；都失败时回退到本地加载
+func (g *Group) loadReplicated(ctx context.Context, key string, replicas []PeerGetter) (interface{}, error) {
+	var remotes []PeerGetter
+	self := false
+	for _, peer := range replicas {
+		if peer == nil {
+			self = true
+		} else {
+			remotes = append(remotes, peer)
+		}
+	}
+
+	if replicas[0] != nil && len(remotes) > 0 {
+		start := 0
+		if !self {
+			start = rand.Intn(len(remotes))
+		}
+		for i := range remotes {
+			peer, peerCtx := remotes[(start+i)%len(remotes)], ctx
+			// 非主节点的副本之间互相请求时要求对方从本地加载，否则主节点不可用时会来回转发
+			if self && peer != replicas[0] {
+				peerCtx = withLocalLoad(ctx)
+			}
+			value, err := g.getFromPeer(peerCtx, peer, key)
+			if err == nil {
+				g.stats.PeerLoads.Add(1)
+				// 本节点也负责该key，保存一份副本
+				if self {
+					g.fillLocally(key, value.ByteSlice())
+				}
+				return value, nil
+			}
+			if errors.Is(err, ErrNotFound) {
+				g.stats.PeerLoads.Add(1)
+				g.negCache.add(key, err)
+				return nil, err
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			log.Println("[GeeCache] Failed to get from replica", err)
+		}
+		g.stats.PeerErrors.Add(1)
+	}
+
+	value, err := g.loadLocally(ctx, key)
+	// 主节点抽样将从数据源加载的值复制到其他副本
+	if err == nil && len(replicas) > 1 && replicas[0] == nil && (g.hotSample <= 1 || rand.Intn(g.hotSample) == 0) {
+		go g.replicate(key, value.ByteSlice(), replicas[1:])
+	}
+	return value, err
+}
+
+// 将key的新值复制到副本节点，复制失败的副本删除旧值，下次读取时重新加载。
+// 返回第一个既没有更新也没有删除旧值的副本的错误
+func (g *Group) replicate(key string, value []byte, replicas []PeerGetter) error {
+	var firstErr error
+	for _, peer := range replicas {
+		if peer == nil {
+			g.fillLocally(key, value)
+			continue
+		}
+		replicator, ok := peer.(PeerReplicator)
+		if !ok {
+			continue
+		}
+		if err := replicator.Replicate(g.name, key, value); err != nil {
+			log.Println("[GeeCache] Failed to replicate", key, err)
+			if remover, ok := peer.(PeerRemover); ok {
+				err = remover.Remove(g.name, key)
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// 只更新本地缓存，不写数据源，用于接收其他节点复制来的值
+func (g *Group) fillLocally(key string, value []byte) {
+	g.negCache.remove(key)
+	g.mainCache.add(key, ByteView{b: cloneBytes(value), e: g.expireAt()})
+}
+
+// 由主节点写数据源，再复制到其他副本
+func (g *Group) setReplicated(key string, value []byte, replicas []PeerGetter) error {
+	if primary := replicas[0]; primary == nil {
+		if err := g.setLocally(key, value); err != nil {
+			return err
+		}
+	} else {
+		setter, ok := primary.(PeerSetter)
+		if !ok {
+			return errors.New("peer does not support set")
+		}
+		if err := setter.Set(g.name, key, value); err != nil {
+			return err
+		}
+		// 本地缓存的旧值和加载失败的结果都已失效，本节点是副本时随后由 replicate 填入新值
+		g.removeLocally(key)
+	}
+	// 数据源已经写入，但有副本可能仍保存着旧值，返回错误
+	return g.replicate(key, value, replicas[1:])
+}
+
+// 从本地和所有远程副本删除key，返回第一个错误
+func (g *Group) removeReplicated(key string, replicas []PeerGetter) error {
+	g.removeLocally(key)
+	var firstErr error
+	for _, peer := range replicas {
+		if peer == nil {
+			continue
+		}
+		remover, ok := peer.(PeerRemover)
+		if !ok {
+			return errors.New("peer does not support remove")
+		}
+		if err := remover.Remove(g.name, key); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,232 @@
+package geecache
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 内存中的副本节点
+type replicaPeer struct {
+	mu         sync.Mutex
+	down       bool
+	gets       int
+	local      int
+	set        map[string]string
+	replicated map[string]string
+	removed    []string
+}
+
+func (p *replicaPeer) Get(group string, key string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gets++
+	if p.down {
+		return nil, errors.New("peer is down")
+	}
+	return []byte("peer-" + key), nil
+}
+
+func (p *replicaPeer) GetContext(ctx context.Context, group string, key string) ([]byte, error) {
+	if isLocalLoad(ctx) {
+		p.mu.Lock()
+		p.local++
+		p.mu.Unlock()
+	}
+	return p.Get(group, key)
+}
+
+func (p *replicaPeer) Set(group string, key string, value []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.set == nil {
+		p.set = make(map[string]string)
+	}
+	p.set[key] = string(value)
+	return nil
+}
+
+func (p *replicaPeer) Replicate(group string, key string, value []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.replicated == nil {
+		p.replicated = make(map[string]string)
+	}
+	p.replicated[key] = string(value)
+	return nil
+}
+
+func (p *replicaPeer) Remove(group string, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removed = append(p.removed, key)
+	return nil
+}
+
+// 固定返回replicas作为所有key的副本
+type replicaPicker struct {
+	replicas []PeerGetter
+}
+
+func (p *replicaPicker) PickPeer(key string) (PeerGetter, bool) {
+	if p.replicas[0] == nil {
+		return nil, false
+	}
+	return p.replicas[0], true
+}
+
+func (p *replicaPicker) PickPeers(key string, n int) []PeerGetter {
+	if n < len(p.replicas) {
+		return p.replicas[:n]
+	}
+	return p.replicas
+}
+
+// 测试读取时本节点是主节点则本地加载，否则从可用的副本获取，所有副本都不可用时才从数据源加载
+func TestReplicationGet(t *testing.T) {
+	a, b := &replicaPeer{}, &replicaPeer{}
+	loads := 0
+	newGroup := func(name string, replicas ...PeerGetter) *Group {
+		g := NewGroup(name, 2<<10, GetterFunc(
+			func(key string) ([]byte, error) {
+				loads++
+				return []byte(key), nil
+			}), WithReplication(3))
+		g.RegisterPeers(&replicaPicker{replicas: replicas})
+		return g
+	}
+
+	// 本节点是第二个副本，先从主节点a获取并保存一份
+	gee := newGroup("replication-self", a, nil, b)
+	for i := 0; i < 2; i++ {
+		if view, err := gee.Get("Tom"); err != nil || view.String() != "peer-Tom" {
+			t.Fatalf("replica should get Tom from the primary, got %s, %v", view, err)
+		}
+	}
+	if loads != 0 || a.gets != 1 || a.local != 0 || b.gets != 0 {
+		t.Fatalf("expect one read from a, loads = %d, a gets = %d, b gets = %d", loads, a.gets, b.gets)
+	}
+
+	// a不可用时从b获取，并要求b从本地加载
+	a.down = true
+	if view, err := gee.Get("Jack"); err != nil || view.String() != "peer-Jack" || b.local != 1 || loads != 0 {
+		t.Fatalf("expect Jack from b loaded locally, got %s, b local = %d, loads = %d", view, b.local, loads)
+	}
+
+	// 所有副本都不可用时才从数据源加载
+	b.down = true
+	if view, err := gee.Get("Lily"); err != nil || view.String() != "Lily" || loads != 1 {
+		t.Fatalf("expect Lily from the origin, got %s, loads = %d", view, loads)
+	}
+
+	// 本节点不是副本，a不可用时从b获取
+	b.down = false
+	gee = newGroup("replication-remote", a, b)
+	for i := 0; i < 2; i++ {
+		gee.mainCache.remove("Jack")
+		gee.hotCache.remove("Jack")
+		if view, err := gee.Get("Jack"); err != nil || view.String() != "peer-Jack" {
+			t.Fatalf("expect Jack from a live replica, got %s, %v", view, err)
+		}
+	}
+	if b.gets == 0 || loads != 1 {
+		t.Fatalf("expect reads from b without local loads, b gets = %d, loads = %d", b.gets, loads)
+	}
+}
+
+// 测试写入由主节点写数据源，再复制到其他副本；删除时删除所有副本
+func TestReplicationSetAndRemove(t *testing.T) {
+	a, b := &replicaPeer{}, &replicaPeer{}
+	gee := NewGroup("replication-set", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return nil, ErrNotFound
+		}), WithReplication(3))
+	gee.RegisterPeers(&replicaPicker{replicas: []PeerGetter{a, nil, b}})
+
+	if err := gee.Set("Tom", []byte("630")); err != nil {
+		t.Fatalf("failed to set Tom: %v", err)
+	}
+	if a.set["Tom"] != "630" || b.replicated["Tom"] != "630" || len(a.replicated) != 0 {
+		t.Fatalf("primary should write and b should get a replica, a = %v, b = %v", a.set, b.replicated)
+	}
+	// 本节点是副本，新值已在本地缓存中
+	if view, err := gee.Get("Tom"); err != nil || view.String() != "630" {
+		t.Fatalf("expect the replicated value 630, got %s, %v", view, err)
+	}
+
+	if err := gee.Remove("Tom"); err != nil {
+		t.Fatalf("failed to remove Tom: %v", err)
+	}
+	if len(a.removed) != 1 || len(b.removed) != 1 {
+		t.Fatalf("remove should reach every replica, a = %v, b = %v", a.removed, b.removed)
+	}
+	// 内存中的副本节点不会真正删除，使其不可用后读取回退到数据源，检查本地的值已被删除
+	a.down, b.down = true, true
+	if _, err := gee.Get("Tom"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Tom should be removed locally, got %v", err)
+	}
+
+	// 本节点不是副本时，写入后本地缓存的旧值失效
+	a.down, b.down = false, false
+	gee = NewGroup("replication-set-remote", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithReplication(2))
+	gee.RegisterPeers(&replicaPicker{replicas: []PeerGetter{a, b}})
+	gee.mainCache.add("Jack", ByteView{b: []byte("589")})
+	if err := gee.Set("Jack", []byte("700")); err != nil {
+		t.Fatalf("failed to set Jack: %v", err)
+	}
+	if view, err := gee.Get("Jack"); err != nil || view.String() != "peer-Jack" {
+		t.Fatalf("stale Jack should be dropped after set, got %s, %v", view, err)
+	}
+}
+
+// 测试通过HTTP复制副本只更新缓存，以及 PickPeers 的顺序
+func TestHTTPReplicate(t *testing.T) {
+	loads := 0
+	NewGroup("http-replica", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}))
+	srv := httptest.NewServer(NewHTTPPool(""))
+	defer srv.Close()
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath}
+
+	if err := h.Replicate("http-replica", "Tom", []byte("630")); err != nil {
+		t.Fatalf("failed to replicate Tom: %v", err)
+	}
+	if b, err := h.Get("http-replica", "Tom"); err != nil || string(b) != "630" || loads != 0 {
+		t.Fatalf("expect the replicated value without loading, got %q, loads = %d", b, loads)
+	}
+
+	pool := NewHTTPPool("http://a")
+	pool.Set("http://a", "http://b", "http://c")
+	nodes := pool.peers.GetN("Tom", 3)
+	peers := pool.PickPeers("Tom", 3)
+	for i, node := range nodes {
+		if node == "http://a" && peers[i] != nil || node != "http://a" && peers[i] != pool.httpGetters[node] {
+			t.Fatalf("PickPeers should follow the ring order %v, self as nil", nodes)
+		}
+	}
+	// 熔断器断开的副本仍在列表中，删除时返回错误而不是忽略它们
+	for _, node := range []string{"http://b", "http://c"} {
+		pool.httpGetters[node].breaker = newBreaker(1, time.Minute)
+		pool.httpGetters[node].breaker.record(true)
+	}
+	if peers := pool.PickPeers("Tom", 3); len(peers) != 3 {
+		t.Fatalf("replicas with an open breaker should not be skipped, got %d", len(peers))
+	}
+	gee := NewGroup("http-replica-breaker", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), WithReplication(3))
+	gee.RegisterPeers(pool)
+	if err := gee.Remove("Tom"); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("expect errCircuitOpen from the unreachable replicas, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package geecache
+
+import (
+	"fmt"
+	"log"
+)
+
+// Setter 接口，将数据写回数据源，与 Getter 相对应
+type Setter interface {
+	Set(key string, value []byte) error
+}
+
+// SetterFunc 实现 Setter 接口——接口型函数
+type SetterFunc func(key string, value []byte) error
+
+// Set 实现 Setter 的接口函数
+func (f SetterFunc) Set(key string, value []byte) error {
+	return f(key, value)
+}
+
+// WriteMode 决定 Group.Set 写数据源和更新缓存的顺序
+type WriteMode int
+
+const (
+	// WriteThrough 先同步写数据源，成功后再更新缓存，Set 返回时数据已经持久化
+	WriteThrough WriteMode = iota
+	// WriteBehind 先更新缓存，再由后台协程异步写数据源，Set 延迟低，但数据源的写入可能失败或丢失
+	WriteBehind
+)
+
+// write-behind 队列的长度，队列满时 Set 会阻塞等待
+const defaultWriteQueueSize = 1024
+
+// 等待异步写入数据源的请求
+type pendingWrite struct {
+	key   string
+	value []byte
+}
+
+// WithSetter 为 Group 设置写回数据源的 Setter 以及写入模式，设置后才能调用 Group.Set
+func WithSetter(setter Setter, mode WriteMode) GroupOption {
+	return func(g *Group) {
+		g.setter = setter
+		g.writeMode = mode
+	}
+}
+
+// Set 更新key对应的值：写入数据源并更新缓存。若key属于远程节点，则转发给该节点处理
+func (g *Group) Set(key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+
+	// 启用副本时由主节点写数据源，再复制到其他副本
+	if replicas := g.pickReplicas(key); len(replicas) > 0 {
+		return g.setReplicated(key, value, replicas)
+	}
+
+	if g.peers != nil {
+		// 必须由key所属的节点写入，该节点不可用时返回错误，而不是写到其他节点上
+		if peer, ok := pickOwner(g.peers, key); ok {
+			setter, ok := peer.(PeerSetter)
+			if !ok {
+				return fmt.Errorf("peer does not support set")
+			}
+			if err := setter.Set(g.name, key, value); err != nil {
+				return err
+			}
+			// 本地缓存的旧值和加载失败的结果都已失效，包括远程节点不可用时本地加载的副本
+			g.removeLocally(key)
+			return nil
+		}
+	}
+	return g.setLocally(key, value)
+}
+
+// 由key所属的节点写入数据源并更新本地缓存，用于处理其他节点转发来的写请求
+func (g *Group) setLocally(key string, value []byte) error {
+	if g.setter == nil {
+		return fmt.Errorf("group %s has no Setter", g.name)
+	}
+
+	// 写入了新值，之前缓存的加载失败结果已经失效
+	g.negCache.remove(key)
+
+	view := ByteView{b: cloneBytes(value), e: g.expireAt()}
+
+	if g.writeMode == WriteBehind {
+		// 先更新缓存，再交给后台协程写数据源
+		g.mainCache.add(key, view)
+		g.pending.Add(1)
+		g.writeQueue <- pendingWrite{key: key, value: view.ByteSlice()}
+		return nil
+	}
+
+	if err := g.setter.Set(key, view.ByteSlice()); err != nil {
+		return err
+	}
+	// 写入的值一定要进入缓存，不经过准入策略
+	g.mainCache.add(key, view)
+	return nil
+}
+
+// 后台协程，按顺序将write-behind队列中的值写入数据源
+func (g *Group) writeBehind() {
+	for w := range g.writeQueue {
+		if err := g.setter.Set(w.key, w.value); err != nil {
+			g.stats.WriteBehindErrs.Add(1)
+			log.Println("[GeeCache] Failed to write behind", w.key, err)
+		}
+		g.pending.Done()
+	}
+}
+
+// Flush 等待所有异步写入完成，WriteThrough 模式下立即返回
+func (g *Group) Flush() {
+	g.pending.Wait()
+}
@@ -0,0 +1,110 @@
+package geecache
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+//用一个加锁的map模拟可写的数据库
+type fakeDB struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (db *fakeDB) Get(key string) ([]byte, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return []byte(db.data[key]), nil
+}
+
+func (db *fakeDB) Set(key string, value []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.data[key] = string(value)
+	return nil
+}
+
+func (db *fakeDB) value(key string) string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.data[key]
+}
+
+func TestSet(t *testing.T) {
+	for name, mode := range map[string]WriteMode{"through": WriteThrough, "behind": WriteBehind} {
+		db := &fakeDB{data: map[string]string{"Tom": "630"}}
+		gee := NewGroup("set-"+name, 2<<10, db, WithSetter(db, mode))
+
+		if err := gee.Set("Tom", []byte("700")); err != nil {
+			t.Fatalf("%s: failed to set Tom: %v", name, err)
+		}
+		//写入后缓存中就是新值
+		if view, ok := gee.mainCache.get("Tom"); !ok || view.String() != "700" {
+			t.Fatalf("%s: cache should hold the new value of Tom", name)
+		}
+		gee.Flush()
+		if v := db.value("Tom"); v != "700" {
+			t.Fatalf("%s: db should hold the new value of Tom, got %s", name, v)
+		}
+	}
+}
+
+func TestSetWithoutSetter(t *testing.T) {
+	gee := NewGroup("set-none", 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	if err := gee.Set("Tom", []byte("700")); err == nil {
+		t.Fatalf("Set should fail without a Setter")
+	}
+}
+
+//测试Set转发给key所属的远程节点
+func TestSetForward(t *testing.T) {
+	db := &fakeDB{data: map[string]string{}}
+	gee := NewGroup("set-forward", 2<<10, db, WithSetter(db, WriteThrough), WithNegativeCache(time.Minute, 1<<10))
+	peer := &fakePeer{}
+	gee.RegisterPeers(peer)
+
+	if err := gee.Set("Tom", []byte("700")); err != nil || peer.set["Tom"] != "700" {
+		t.Fatalf("set should be forwarded to the owning peer")
+	}
+	if v := db.value("Tom"); v != "" {
+		t.Fatalf("db should only be written by the owning peer")
+	}
+
+	//转发成功后本地缓存的旧值和不存在的结果都失效
+	gee.mainCache.add("Jack", ByteView{b: []byte("589")})
+	gee.negCache.add("Sam", ErrNotFound)
+	for _, key := range []string{"Jack", "Sam"} {
+		if err := gee.Set(key, []byte("700")); err != nil {
+			t.Fatalf("failed to set %s: %v", key, err)
+		}
+		if view, err := gee.Get(key); err != nil || view.String() != key {
+			t.Fatalf("%s should be read from the peer after set, got %s, %v", key, view, err)
+		}
+	}
+}
+
+//测试通过HTTP的PUT请求由key所属的节点写入
+func TestSetFromPeer(t *testing.T) {
+	db := &fakeDB{data: map[string]string{}}
+	gee := NewGroup("set-http", 2<<10, db, WithSetter(db, WriteThrough))
+
+	peers := NewHTTPPool("")
+	srv := httptest.NewServer(peers)
+	defer srv.Close()
+	h := &httpGetter{baseURL: srv.URL + defaultBasePath}
+
+	if err := h.Set("set-http", "Tom", []byte("700")); err != nil {
+		t.Fatalf("failed to set Tom through peer: %v", err)
+	}
+	if v := db.value("Tom"); v != "700" {
+		t.Fatalf("db should hold the new value of Tom, got %s", v)
+	}
+	if view, err := gee.Get("Tom"); err != nil || view.String() != "700" {
+		t.Fatalf("cache should hold the new value of Tom")
+	}
+}
@@ -1,12 +1,65 @@
 package singleflight
 
-import "sync"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
 
-// call代表进行中或已结束的请求，waitgroup锁避免重入
+// fn调用了runtime.Goexit时，等待者收到的错误
+var errGoexit = errors.New("runtime.Goexit was called")
+
+// panicError 记录fn中发生的panic及其调用栈，在每个等待者中重新panic
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *panicError) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
+
+// 如果panic的值是error，支持errors.Is/As
+func (p *panicError) Unwrap() error {
+	err, _ := p.value.(error)
+	return err
+}
+
+func newPanicError(v interface{}) error {
+	stack := debug.Stack()
+	// 第一行是"goroutine N [status]:"，重新panic时会与新的goroutine信息混淆，去掉
+	if line := bytes.IndexByte(stack, '\n'); line >= 0 {
+		stack = stack[line+1:]
+	}
+	return &panicError{value: v, stack: stack}
+}
+
+// call代表进行中或已结束的请求，done在请求结束时关闭，所有等待者据此获得结果
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done chan struct{}
+	val  interface{}
+	err  error
+	// fn是否在单独的协程中执行（DoChan、DoContext），此时Goexit只结束了该协程
+	async bool
+
+	// 以下字段由Group.mu保护
+	dups    int                // 复用该请求的调用方数量，大于0时结果是共享的
+	chans   []chan<- Result    // DoChan的调用方，请求结束时向其发送结果
+	waiters int                // 仍在等待结果的调用方数量，降为0时取消fn
+	cancel  context.CancelFunc // 取消传给fn的context，只有DoContext发起的请求才有
+}
+
+// Result 是DoChan返回的结果，Shared表示结果是否与其他调用方共享
+type Result struct {
+	Val    interface{}
+	Err    error
+	Shared bool
 }
 
 // singleflight的主体结构，管理不同key的请求(call)
@@ -15,34 +68,210 @@ type Group struct {
 	m  map[string]*call // m 维护 key 与其对应的 call 请求
 }
 
-// 对相应的key请求进行处理，传入匿名函数fn用于获取key对应的val，Do函数使用sync.WaitGruop使得同一个key无论调用多少次Do，fn只会执行一次
-func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+// 对相应的key请求进行处理，传入匿名函数fn用于获取key对应的val，同一个key无论调用多少次Do，同一时刻fn只会执行一次
+// shared表示结果是否与其他调用方共享
+func (g *Group) Do(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
 	// 加锁保护map
 	g.mu.Lock()
 	// 延迟初始化
 	if g.m == nil {
 		g.m = make(map[string]*call)
 	}
-	// 如果已经有关于该key的请求，Wait等待对应key的所有请求结束再返回
+	// 如果已经有关于该key的请求，等待该请求结束再返回
 	if c, ok := g.m[key]; ok {
+		// Do不能中途离开，因此该请求不会因为其他调用方离开而被取消
+		c.dups++
+		c.waiters++
 		g.mu.Unlock()
-		c.wg.Wait()
-		return c.val, c.err // TODO：val如何获得？
+		<-c.done
+		// fn发生panic时在每个等待者中重现；同步执行的fn调用了runtime.Goexit时等待者同样退出，
+		// 异步执行时Goexit只结束了fn所在的协程，与 wait 一致返回errGoexit
+		if e, ok := c.err.(*panicError); ok {
+			panic(e)
+		} else if c.err == errGoexit && !c.async {
+			runtime.Goexit()
+		}
+		return c.val, c.err, true
 	}
-	// 如果该key目前没有请求，新建一个并使WaitGroup锁的计数+1
-	c := new(call)
-	c.wg.Add(1)
+	// 如果该key目前没有请求，新建一个
+	c := &call{done: make(chan struct{}), waiters: 1}
 	g.m[key] = c
+	// 注册完成后释放锁，其他请求才能进入并等待这个call
+	g.mu.Unlock()
 
 	// call执行传进来的fn函数获取val
-	c.val, c.err = fn()
-	// Done使WaitGroup锁的计数-1
-	c.wg.Done()
+	g.doCall(c, key, fn, false)
+	return c.val, c.err, c.dups > 0
+}
 
-	// call请求结束后，对map进行加锁，并删除该key对应的映射
+// DoChan 与 Do 类似，但不阻塞，而是返回一个在结果就绪时接收Result的channel，便于与其他channel一起select
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.waiters++
+		c.chans = append(c.chans, ch)
+		g.mu.Unlock()
+		return ch
+	}
+	c := &call{done: make(chan struct{}), waiters: 1, chans: []chan<- Result{ch}, async: true}
+	g.m[key] = c
+	g.mu.Unlock()
+
+	go g.doCall(c, key, fn, true)
+	return ch
+}
+
+// Forget 使Group忘记key对应的请求，之后的调用会发起新的请求，而不是等待进行中的请求
+func (g *Group) Forget(key string) {
 	g.mu.Lock()
 	delete(g.m, key)
 	g.mu.Unlock()
+}
+
+// DoContext 与 Do 类似，但每个调用方都可以在自己的ctx结束时提前返回ctx.Err()，共享的请求仍会继续执行。
+// fn在单独的协程中执行，收到的context保留了发起者ctx中的值，但不会随发起者一起取消；
+// 只有当所有调用方都离开后，fn的context才会被取消，并且之后的调用会发起新的请求
+func (g *Group) DoContext(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (v interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.waiters++
+		g.mu.Unlock()
+		return g.wait(ctx, key, c)
+	}
+	callCtx, cancel := context.WithCancel(withoutCancel{ctx})
+	c := &call{done: make(chan struct{}), waiters: 1, cancel: cancel, async: true}
+	g.m[key] = c
+	g.mu.Unlock()
+
+	go g.doCall(c, key, func() (interface{}, error) {
+		return fn(callCtx)
+	}, true)
+	return g.wait(ctx, key, c)
+}
+
+// 等待请求结束或ctx结束，最后一个离开的调用方负责取消请求
+func (g *Group) wait(ctx context.Context, key string, c *call) (interface{}, error, bool) {
+	select {
+	case <-c.done:
+		// fn的panic在每个等待者中重现；fn在单独的协程中执行，Goexit只结束了该协程，因此返回错误
+		if e, ok := c.err.(*panicError); ok {
+			panic(e)
+		}
+		g.mu.Lock()
+		shared := c.dups > 0
+		g.mu.Unlock()
+		return c.val, c.err, shared
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	c.waiters--
+	if c.waiters == 0 && c.cancel != nil {
+		c.cancel()
+		// 已取消的请求不再被后来者复用
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		// fn与ctx同时结束时，doCall可能已经把panic留给了这个等待者，但它选择了离开
+		select {
+		case <-c.done:
+			if e, ok := c.err.(*panicError); ok {
+				logPanic(key, e)
+			}
+		default:
+		}
+	}
+	g.mu.Unlock()
+	return nil, ctx.Err(), false
+}
 
-	return c.val, c.err
+// 异步执行的fn发生panic时所有调用方都已离开，没有人能recover，记录日志后丢弃，而不是让整个进程崩溃
+func logPanic(key string, e *panicError) {
+	log.Printf("[GeeCache] singleflight: %s panicked after all callers left: %v", key, e)
 }
+
+// 执行fn，结束后唤醒所有等待者，并删除key对应的映射。
+// 无论fn正常返回、panic还是调用runtime.Goexit，都保证唤醒等待者并清理映射，避免之后的调用永远阻塞。
+// async表示fn在单独的协程中执行（DoChan、DoContext），此时panic无法交给发起者处理
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error), async bool) {
+	normalReturn := false
+	recovered := false
+
+	// 外层defer在fn结束后总会执行，用于唤醒等待者和清理映射
+	defer func() {
+		// 既没有正常返回也没有recover到panic，说明fn调用了runtime.Goexit
+		if !normalReturn && !recovered {
+			c.err = errGoexit
+		}
+
+		// call请求结束后，对map进行加锁，并删除该key对应的映射
+		g.mu.Lock()
+		close(c.done)
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		if c.cancel != nil {
+			c.cancel()
+		}
+		chans, waiters := c.chans, c.waiters
+		// 向DoChan的调用方发送结果，channel带缓冲，不会阻塞
+		if _, ok := c.err.(*panicError); !ok {
+			for _, ch := range chans {
+				ch <- Result{Val: c.val, Err: c.err, Shared: c.dups > 0}
+			}
+		}
+		g.mu.Unlock()
+
+		if e, ok := c.err.(*panicError); ok {
+			// DoChan的调用方无法recover这个panic，与其让它们永远等待，不如让程序崩溃；
+			// 在新协程中panic使其无法被recover，select保留当前协程以便出现在崩溃信息中
+			if len(chans) > 0 {
+				go panic(e)
+				select {}
+			}
+			// 同步执行时由发起者重新panic；异步执行且仍有等待者时，由等待者在wait中重新panic
+			if !async {
+				panic(e)
+			}
+			if waiters == 0 {
+				logPanic(key, e)
+			}
+		}
+	}()
+
+	// 内层函数的defer只在fn panic时recover，Goexit不会触发recover
+	func() {
+		defer func() {
+			if !normalReturn {
+				if r := recover(); r != nil {
+					c.err = newPanicError(r)
+				}
+			}
+		}()
+		c.val, c.err = fn()
+		normalReturn = true
+	}()
+
+	if !normalReturn {
+		recovered = true
+	}
+}
+
+// withoutCancel 保留父context中的值，但不继承它的截止时间和取消信号
+type withoutCancel struct {
+	parent context.Context
+}
+
+func (withoutCancel) Deadline() (deadline time.Time, ok bool) { return }
+func (withoutCancel) Done() <-chan struct{}                   { return nil }
+func (withoutCancel) Err() error                              { return nil }
+func (c withoutCancel) Value(key interface{}) interface{}     { return c.parent.Value(key) }
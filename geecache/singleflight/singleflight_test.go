@@ -0,0 +1,364 @@
+package singleflight
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	var g Group
+	v, err, shared := g.Do("key", func() (interface{}, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil || shared {
+		t.Fatalf("Do = %v, %v, %v; want bar, nil, false", v, err, shared)
+	}
+}
+
+// 并发调用Do时fn只执行一次
+func TestDoDupSuppress(t *testing.T) {
+	var g Group
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err, shared := g.Do("key", fn); v != "bar" || err != nil || !shared {
+				t.Errorf("Do = %v, %v, %v; want bar, nil, true", v, err, shared)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("fn should be called once, got %d", calls)
+	}
+}
+
+// 调用方的ctx结束后立即返回，共享的请求继续为其他调用方执行
+func TestDoContextWaiterLeaves(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-release:
+			return "bar", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err, _ := g.DoContext(ctx, "key", fn)
+		result <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	waiter := make(chan interface{}, 1)
+	go func() {
+		v, _, _ := g.DoContext(context.Background(), "key", fn)
+		waiter <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller should get context.Canceled, got %v", err)
+	}
+	close(release)
+	if v := <-waiter; v != "bar" {
+		t.Fatalf("remaining caller should get bar, got %v", v)
+	}
+}
+
+// 所有调用方都离开后取消fn，之后的调用发起新的请求
+func TestDoContextCancelFn(t *testing.T) {
+	var g Group
+	fnCancelled := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(fnCancelled)
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err, _ := g.DoContext(ctx, "key", fn); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect context.DeadlineExceeded, got %v", err)
+	}
+	select {
+	case <-fnCancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("fn should be cancelled after every caller left")
+	}
+
+	v, err, _ := g.DoContext(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil {
+		t.Fatalf("DoContext = %v, %v; want bar, nil", v, err)
+	}
+}
+
+// fn收到的context保留调用方ctx中的值
+func TestDoContextValue(t *testing.T) {
+	var g Group
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "bar")
+	v, _, _ := g.DoContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		return ctx.Value(ctxKey{}), nil
+	})
+	if v != "bar" {
+		t.Fatalf("fn should see values of the caller's context, got %v", v)
+	}
+}
+
+func TestDoChan(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}
+
+	ch1 := g.DoChan("key", fn)
+	ch2 := g.DoChan("key", fn)
+	close(release)
+	for _, ch := range []<-chan Result{ch1, ch2} {
+		select {
+		case res := <-ch:
+			if res.Val != "bar" || res.Err != nil || !res.Shared {
+				t.Fatalf("DoChan = %+v; want bar, nil, shared", res)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("DoChan timeout")
+		}
+	}
+}
+
+// Forget之后的调用不再等待进行中的请求
+func TestForget(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	first := g.DoChan("key", func() (interface{}, error) {
+		<-release
+		return 1, nil
+	})
+
+	g.Forget("key")
+	v, _, shared := g.Do("key", func() (interface{}, error) {
+		return 2, nil
+	})
+	if v != 2 || shared {
+		t.Fatalf("Do after Forget = %v, %v; want 2, false", v, shared)
+	}
+
+	close(release)
+	if res := <-first; res.Val != 1 {
+		t.Fatalf("forgotten call should still finish with 1, got %v", res.Val)
+	}
+}
+
+// fn发生panic时，发起者和等待者都会panic，并且key被清理，之后的调用不会阻塞
+func TestDoPanic(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		panic("boom")
+	}
+
+	var wg sync.WaitGroup
+	var panics int32
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					if e, ok := r.(*panicError); !ok || e.value != "boom" {
+						t.Errorf("expect panicError boom, got %v", r)
+					}
+					atomic.AddInt32(&panics, 1)
+				}
+			}()
+			g.Do("key", fn)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if panics != 3 {
+		t.Fatalf("every caller should panic, got %d", panics)
+	}
+	if len(g.m) != 0 {
+		t.Fatalf("key should be removed after panic")
+	}
+	if v, err, _ := g.Do("key", func() (interface{}, error) { return "bar", nil }); v != "bar" || err != nil {
+		t.Fatalf("Do after panic = %v, %v; want bar, nil", v, err)
+	}
+}
+
+// fn调用runtime.Goexit时，等待者同样退出，并且key被清理
+func TestDoGoexit(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		runtime.Goexit()
+		return nil, nil
+	}
+
+	var wg sync.WaitGroup
+	var returned int32
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Do("key", fn)
+			atomic.AddInt32(&returned, 1)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if returned != 0 {
+		t.Fatalf("callers should exit with runtime.Goexit, %d returned", returned)
+	}
+	if v, err, _ := g.Do("key", func() (interface{}, error) { return "bar", nil }); v != "bar" || err != nil {
+		t.Fatalf("Do after Goexit = %v, %v; want bar, nil", v, err)
+	}
+}
+
+// DoContext中fn发生panic时，在调用方重新panic
+func TestDoContextPanic(t *testing.T) {
+	var g Group
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("DoContext should panic")
+		}
+		if v, err, _ := g.DoContext(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+			return "bar", nil
+		}); v != "bar" || err != nil {
+			t.Fatalf("DoContext after panic = %v, %v; want bar, nil", v, err)
+		}
+	}()
+	g.DoContext(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		panic("boom")
+	})
+}
+
+// 所有调用方离开后fn才panic时，记录日志而不是让进程崩溃
+func TestDoContextPanicAfterCallersLeft(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	log.SetOutput(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return buf.Write(p)
+	}))
+	defer log.SetOutput(os.Stderr)
+
+	var g Group
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	release := make(chan struct{})
+	_, err, _ := g.DoContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		<-release
+		panic("boom")
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+	close(release)
+
+	for i := 0; ; i++ {
+		mu.Lock()
+		logged := strings.Contains(buf.String(), "boom")
+		mu.Unlock()
+		if logged {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("the panic should be logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// DoContext中fn调用runtime.Goexit时，调用方收到错误而不是永远等待
+func TestDoContextGoexit(t *testing.T) {
+	var g Group
+	_, err, _ := g.DoContext(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		runtime.Goexit()
+		return nil, nil
+	})
+	if err != errGoexit {
+		t.Fatalf("expect errGoexit, got %v", err)
+	}
+}
+
+// DoContext中fn调用runtime.Goexit时，复用该请求的Do调用方收到错误，而不是随之退出
+func TestDoJoinAsyncGoexit(t *testing.T) {
+	var g Group
+	started, release := make(chan struct{}), make(chan struct{})
+	go g.DoContext(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		runtime.Goexit()
+		return nil, nil
+	})
+	<-started
+
+	done := make(chan error)
+	go func() {
+		_, err, _ := g.Do("key", func() (interface{}, error) { return "bar", nil })
+		done <- err
+	}()
+	// 等待Do加入进行中的请求后再结束fn
+	for {
+		g.mu.Lock()
+		dups := g.m["key"].dups
+		g.mu.Unlock()
+		if dups > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	select {
+	case err := <-done:
+		if err != errGoexit {
+			t.Fatalf("expect errGoexit, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Do should return after the async fn called runtime.Goexit")
+	}
+}
@@ -0,0 +1,226 @@
+package geecache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 快照文件格式（整数均为大端序）：
+//
+//	magic   [4]byte  "GEEC"
+//	version uint16   当前为1
+//	entry*           每个条目以1开头：flag(1) keyLen(uvarint) key valueLen(uvarint) value expire(int64，UnixNano，0表示永不过期)
+//	end     byte     0
+//	crc     uint32   之前所有字节的CRC-32（IEEE）
+//
+// 条目按淘汰顺序写入，恢复时依次加入缓存即可还原LRU顺序
+
+const (
+	snapshotMagic   = "GEEC"
+	snapshotVersion = 1
+)
+
+var (
+	// ErrBadSnapshot 表示快照不是geecache的快照，或者已经损坏
+	ErrBadSnapshot = errors.New("geecache: bad snapshot")
+)
+
+// Snapshot 将mainCache中所有未过期的条目写入w
+func (g *Group) Snapshot(w io.Writer) error {
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(w, crc))
+
+	bw.WriteString(snapshotMagic)
+	binary.Write(bw, binary.BigEndian, uint16(snapshotVersion))
+	var buf [binary.MaxVarintLen64]byte
+	for _, e := range g.mainCache.entries() {
+		bw.WriteByte(1)
+		bw.Write(buf[:binary.PutUvarint(buf[:], uint64(len(e.key)))])
+		bw.WriteString(e.key)
+		bw.Write(buf[:binary.PutUvarint(buf[:], uint64(len(e.value.b)))])
+		bw.Write(e.value.b)
+		var expire int64
+		if !e.value.e.IsZero() {
+			expire = e.value.e.UnixNano()
+		}
+		binary.Write(bw, binary.BigEndian, expire)
+	}
+	bw.WriteByte(0)
+	// 先把缓冲区写完，crc才包含了所有内容
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+// Restore 从r读取Snapshot写入的快照并加入mainCache，已过期的条目会被跳过
+// 快照校验失败时返回ErrBadSnapshot，且不会修改缓存
+func (g *Group) Restore(r io.Reader) error {
+	crc := crc32.NewIEEE()
+	br := bufio.NewReader(r)
+	tr := &crcReader{r: br, crc: crc}
+
+	entries, err := readSnapshot(tr)
+	if err != nil {
+		return err
+	}
+	var sum uint32
+	if err := binary.Read(br, binary.BigEndian, &sum); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+	}
+	if sum != crc.Sum32() {
+		return fmt.Errorf("%w: checksum mismatch", ErrBadSnapshot)
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		if !e.value.e.IsZero() && now.After(e.value.e) {
+			continue
+		}
+		g.mainCache.add(e.key, e.value)
+	}
+	return nil
+}
+
+// 读取快照中crc之前的部分
+func readSnapshot(r *crcReader) ([]cacheEntry, error) {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return nil, ErrBadSnapshot
+	}
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+	}
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrBadSnapshot, version)
+	}
+
+	var entries []cacheEntry
+	for {
+		flag, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+		}
+		if flag == 0 {
+			return entries, nil
+		}
+		key, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		var expire int64
+		if err := binary.Read(r, binary.BigEndian, &expire); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+		}
+		view := ByteView{b: value}
+		if expire != 0 {
+			view.e = time.Unix(0, expire)
+		}
+		entries = append(entries, cacheEntry{key: string(key), value: view})
+	}
+}
+
+// 读取以uvarint长度开头的字节串
+func readBytes(r *crcReader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+	}
+	if n > 1<<32 {
+		return nil, fmt.Errorf("%w: length %d too large", ErrBadSnapshot, n)
+	}
+	// 长度在校验crc之前无法确认，不能按它一次分配内存，否则损坏的快照会使节点在启动时耗尽内存；
+	// 边读边扩容，分配的内存不超过实际读到的数据
+	var b bytes.Buffer
+	if _, err := io.CopyN(&b, r, int64(n)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+	}
+	return b.Bytes(), nil
+}
+
+// crcReader 在读取的同时计算crc
+type crcReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (c *crcReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.crc.Write(p[:n])
+	return n, err
+}
+
+func (c *crcReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.crc.Write([]byte{b})
+	}
+	return b, err
+}
+
+// WithSnapshot 使Group在创建时从path恢复快照，并每隔interval将快照保存到path，使节点重启后缓存仍是热的
+func WithSnapshot(path string, interval time.Duration) GroupOption {
+	return func(g *Group) {
+		g.snapshotPath = path
+		g.snapshotInterval = interval
+	}
+}
+
+// 从快照文件恢复，文件不存在时什么也不做
+func (g *Group) restoreFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return g.Restore(f)
+}
+
+// 将快照保存到文件：先写临时文件再重命名，保证文件要么是旧快照要么是完整的新快照
+func (g *Group) saveFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := g.Snapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// 后台协程，定期保存快照，同名的新Group替换了g之后退出，避免两个协程写同一个文件
+func (g *Group) snapshotLoop(path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if GetGroup(g.name) != g {
+			return
+		}
+		if err := g.saveFile(path); err != nil {
+			log.Println("[GeeCache] Failed to save snapshot", err)
+		}
+	}
+}
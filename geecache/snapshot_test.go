@@ -0,0 +1,107 @@
+package geecache
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func newSnapshotGroup(name string, opts ...GroupOption) *Group {
+	return NewGroup(name, 2<<10, GetterFunc(
+		func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}), opts...)
+}
+
+func cacheKeys(c *cache) []string {
+	keys := make([]string, 0)
+	for _, e := range c.entries() {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+//测试快照可以还原条目、LRU顺序和过期时间
+func TestSnapshotRestore(t *testing.T) {
+	src := newSnapshotGroup("snapshot-src")
+	expire := time.Now().Add(time.Hour)
+	src.mainCache.add("Tom", ByteView{b: []byte("630"), e: expire})
+	src.mainCache.add("Jack", ByteView{b: []byte("589")})
+	src.mainCache.add("Sam", ByteView{b: []byte("567"), e: time.Now().Add(-time.Second)})
+	src.mainCache.get("Tom")
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatalf("failed to snapshot: %v", err)
+	}
+	dst := newSnapshotGroup("snapshot-dst")
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+
+	//Sam已过期，不会写入快照
+	if keys := cacheKeys(&dst.mainCache); !reflect.DeepEqual(keys, []string{"Jack", "Tom"}) {
+		t.Fatalf("LRU order should be kept, got %s", keys)
+	}
+	if view, ok := dst.mainCache.get("Tom"); !ok || view.String() != "630" || !view.Expire().Equal(expire) {
+		t.Fatalf("Tom should be restored with its expiration")
+	}
+}
+
+//测试损坏的快照不会被恢复
+func TestRestoreCorrupted(t *testing.T) {
+	src := newSnapshotGroup("snapshot-corrupted-src")
+	src.mainCache.add("Tom", ByteView{b: []byte("630")})
+	var buf bytes.Buffer
+	src.Snapshot(&buf)
+
+	data := buf.Bytes()
+	data[len(data)-6] ^= 0xff
+	dst := newSnapshotGroup("snapshot-corrupted-dst")
+	if err := dst.Restore(bytes.NewReader(data)); !errors.Is(err, ErrBadSnapshot) {
+		t.Fatalf("expect ErrBadSnapshot, got %v", err)
+	}
+	if _, ok := dst.mainCache.get("Tom"); ok {
+		t.Fatalf("corrupted snapshot should not be restored")
+	}
+	if err := dst.Restore(bytes.NewReader([]byte("not a snapshot"))); !errors.Is(err, ErrBadSnapshot) {
+		t.Fatalf("expect ErrBadSnapshot, got %v", err)
+	}
+}
+
+//测试快照中的长度损坏时，不会按该长度分配内存
+func TestRestoreHugeLength(t *testing.T) {
+	data := []byte(snapshotMagic + "\x00\x01\x01")
+	data = append(data, 0xff, 0xff, 0xff, 0xff, 0x0f) // 长度为 1<<32 - 1
+	data = append(data, "Tom"...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err := newSnapshotGroup("snapshot-huge").Restore(bytes.NewReader(data))
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrBadSnapshot) {
+		t.Fatalf("expect ErrBadSnapshot, got %v", err)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Fatalf("restore allocated %d bytes for a corrupted length", alloc)
+	}
+}
+
+//测试定期保存快照，新建Group时从快照文件恢复
+func TestSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scores.snapshot")
+	src := newSnapshotGroup("snapshot-file", WithSnapshot(path, 10*time.Millisecond))
+	src.Get("Tom")
+	time.Sleep(50 * time.Millisecond)
+
+	dst := newSnapshotGroup("snapshot-file", WithSnapshot(path, 0))
+	if _, ok := dst.mainCache.get("Tom"); !ok {
+		t.Fatalf("Tom should be restored from %s", path)
+	}
+	//src已被同名的dst替换，等待它的后台协程退出后再清理临时目录
+	time.Sleep(50 * time.Millisecond)
+}
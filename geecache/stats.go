@@ -0,0 +1,87 @@
+package geecache
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// AtomicInt 是可以并发读写的int64计数器
+type AtomicInt int64
+
+// Add 原子地将n加到计数器上
+func (i *AtomicInt) Add(n int64) {
+	atomic.AddInt64((*int64)(i), n)
+}
+
+// Get 原子地读取计数器的值
+func (i *AtomicInt) Get() int64 {
+	return atomic.LoadInt64((*int64)(i))
+}
+
+func (i *AtomicInt) String() string {
+	return strconv.FormatInt(i.Get(), 10)
+}
+
+// Group 内部使用的计数器
+type groupStats struct {
+	Gets            AtomicInt // 所有Get请求，包括来自其他节点的请求
+	CacheHits       AtomicInt // 缓存命中次数
+	NegativeHits    AtomicInt // 负缓存命中次数
+	Loads           AtomicInt // 缓存未命中需要加载的次数（Gets - CacheHits - NegativeHits），不含后台刷新
+	LoadsDeduped    AtomicInt // 经过singleflight去重后实际执行的加载次数
+	LoadsShared     AtomicInt // 与其他并发请求共享了加载结果的次数（singleflight的shared）
+	Refreshes       AtomicInt // 启动后台刷新的次数
+	PeerLoads       AtomicInt // 从远程节点加载成功的次数
+	PeerErrors      AtomicInt // 从远程节点加载失败的次数
+	LocalLoads      AtomicInt // 从本地数据源加载成功的次数
+	LocalLoadErrs   AtomicInt // 从本地数据源加载失败的次数
+	ServerRequests  AtomicInt // 收到其他节点请求的次数
+	WriteBehindErrs AtomicInt // 异步写入数据源失败的次数
+}
+
+// Stats 是 Group 统计数据的快照，由 Group.Stats 返回
+type Stats struct {
+	Gets            int64
+	CacheHits       int64
+	NegativeHits    int64
+	Loads           int64
+	LoadsDeduped    int64
+	LoadsShared     int64
+	Refreshes       int64
+	PeerLoads       int64
+	PeerErrors      int64
+	LocalLoads      int64
+	LocalLoadErrs   int64
+	ServerRequests  int64
+	WriteBehindErrs int64
+	MainCache       CacheStats
+	HotCache        CacheStats
+}
+
+// CacheStats 是缓存层统计数据的快照
+type CacheStats struct {
+	Items     int64 // 条目数量
+	Bytes     int64 // 已用容量
+	Evictions int64 // 因容量不足或过期被淘汰的条目数量，不包括主动删除
+}
+
+// Stats 返回 Group 当前统计数据的快照，可用于导出监控指标
+func (g *Group) Stats() Stats {
+	return Stats{
+		Gets:            g.stats.Gets.Get(),
+		CacheHits:       g.stats.CacheHits.Get(),
+		NegativeHits:    g.stats.NegativeHits.Get(),
+		Loads:           g.stats.Loads.Get(),
+		LoadsDeduped:    g.stats.LoadsDeduped.Get(),
+		LoadsShared:     g.stats.LoadsShared.Get(),
+		Refreshes:       g.stats.Refreshes.Get(),
+		PeerLoads:       g.stats.PeerLoads.Get(),
+		PeerErrors:      g.stats.PeerErrors.Get(),
+		LocalLoads:      g.stats.LocalLoads.Get(),
+		LocalLoadErrs:   g.stats.LocalLoadErrs.Get(),
+		ServerRequests:  g.stats.ServerRequests.Get(),
+		WriteBehindErrs: g.stats.WriteBehindErrs.Get(),
+		MainCache:       g.mainCache.stats(),
+		HotCache:        g.hotCache.stats(),
+	}
+}
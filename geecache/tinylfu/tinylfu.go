@@ -0,0 +1,181 @@
+package tinylfu
+
+// 参考：Einziger et al., "TinyLFU: A Highly Efficient Cache Admission Policy"
+
+// TinyLFU 是缓存的准入策略：用 Count-Min Sketch 近似统计每个key最近的访问频率，
+// 新条目只有在频率高于将被它挤出的淘汰对象时才允许进入缓存，避免一次性扫描冲刷热点数据。
+// 第一次出现的key只记录在doorkeeper（布隆过滤器）中，第二次出现才计入sketch，减少只访问一次的key对sketch的占用。
+// 对并发访问不安全
+type TinyLFU struct {
+	sketch     *cmSketch
+	doorkeeper *bloom
+	samples    int // 访问次数达到samples后所有计数减半，使频率统计偏向最近的访问
+	count      int // 自上次减半以来的访问次数
+}
+
+// 用于实例化的New()函数，samples通常取缓存预计条目数的10倍左右
+func New(samples int) *TinyLFU {
+	if samples < 16 {
+		samples = 16
+	}
+	return &TinyLFU{
+		sketch:     newCMSketch(samples),
+		doorkeeper: newBloom(samples),
+		samples:    samples,
+	}
+}
+
+// 记录一次对key的访问
+func (t *TinyLFU) Increment(key string) {
+	h := hash(key)
+	t.count++
+	if t.count >= t.samples {
+		t.reset()
+	}
+	// 第一次出现的key只记入doorkeeper
+	if t.doorkeeper.add(h) {
+		return
+	}
+	t.sketch.increment(h)
+}
+
+// 估算key最近的访问频率
+func (t *TinyLFU) Estimate(key string) int {
+	h := hash(key)
+	n := t.sketch.estimate(h)
+	if t.doorkeeper.has(h) {
+		n++
+	}
+	return n
+}
+
+// 判断candidate能否挤出victim进入缓存，频率相同时保留victim
+func (t *TinyLFU) Admit(candidate, victim string) bool {
+	return t.Estimate(candidate) > t.Estimate(victim)
+}
+
+// 衰减：清空doorkeeper，sketch中的计数减半
+func (t *TinyLFU) reset() {
+	t.count = 0
+	t.doorkeeper.reset()
+	t.sketch.halve()
+}
+
+// 64位FNV-1a哈希
+func hash(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+const (
+	cmDepth    = 4  // sketch的行数，每行使用不同的哈希位置
+	cmMaxCount = 15 // 计数上限，与论文一样只需要4个bit
+)
+
+// Count-Min Sketch，估计值取各行计数的最小值
+type cmSketch struct {
+	rows [cmDepth][]uint8
+	mask uint64
+}
+
+func newCMSketch(width int) *cmSketch {
+	w := nextPowerOfTwo(width)
+	s := &cmSketch{mask: uint64(w - 1)}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+// 由一个64位哈希值派生出第i行的下标
+func (s *cmSketch) index(h uint64, i int) uint64 {
+	h1, h2 := h&0xffffffff, h>>32
+	return (h1 + uint64(i)*h2) & s.mask
+}
+
+func (s *cmSketch) increment(h uint64) {
+	for i := range s.rows {
+		idx := s.index(h, i)
+		if s.rows[i][idx] < cmMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+}
+
+func (s *cmSketch) estimate(h uint64) int {
+	min := uint8(cmMaxCount)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(h, i)]; v < min {
+			min = v
+		}
+	}
+	return int(min)
+}
+
+func (s *cmSketch) halve() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+}
+
+// 只用于doorkeeper的简单布隆过滤器
+type bloom struct {
+	bits []uint64
+	mask uint64
+}
+
+const bloomHashes = 3
+
+func newBloom(n int) *bloom {
+	// 每个元素约占8个bit
+	w := nextPowerOfTwo(n * 8)
+	if w < 64 {
+		w = 64
+	}
+	return &bloom{bits: make([]uint64, w/64), mask: uint64(w - 1)}
+}
+
+// 加入哈希值h，返回h此前是否不存在
+func (b *bloom) add(h uint64) bool {
+	h1, h2 := h&0xffffffff, h>>32
+	added := false
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) & b.mask
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			b.bits[bit/64] |= 1 << (bit % 64)
+			added = true
+		}
+	}
+	return added
+}
+
+func (b *bloom) has(h uint64) bool {
+	h1, h2 := h&0xffffffff, h>>32
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) & b.mask
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloom) reset() {
+	for i := range b.bits {
+		b.bits[i] = 0
+	}
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
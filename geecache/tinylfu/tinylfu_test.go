@@ -0,0 +1,54 @@
+package tinylfu
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	lfu := New(1000)
+	for i := 0; i < 5; i++ {
+		lfu.Increment("hot")
+	}
+	lfu.Increment("cold")
+
+	// 第一次访问只记录在doorkeeper中
+	if n := lfu.Estimate("cold"); n != 1 {
+		t.Fatalf("estimate of cold should be 1, got %d", n)
+	}
+	if n := lfu.Estimate("hot"); n != 5 {
+		t.Fatalf("estimate of hot should be 5, got %d", n)
+	}
+	if n := lfu.Estimate("unknown"); n != 0 {
+		t.Fatalf("estimate of unknown should be 0, got %d", n)
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	lfu := New(1000)
+	for i := 0; i < 3; i++ {
+		lfu.Increment("hot")
+	}
+	lfu.Increment("scan")
+
+	if lfu.Admit("scan", "hot") {
+		t.Fatalf("scan should not evict hot")
+	}
+	if !lfu.Admit("hot", "scan") {
+		t.Fatalf("hot should evict scan")
+	}
+}
+
+// 访问次数达到samples后计数减半
+func TestReset(t *testing.T) {
+	lfu := New(100)
+	for i := 0; i < 9; i++ {
+		lfu.Increment("hot")
+	}
+	for i := 0; lfu.count != 0; i++ {
+		lfu.Increment("k" + strconv.Itoa(i))
+	}
+	if n := lfu.Estimate("hot"); n != 4 {
+		t.Fatalf("estimate of hot should be halved to 4, got %d", n)
+	}
+}
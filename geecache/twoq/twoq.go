@@ -0,0 +1,263 @@
+package twoq
+
+import (
+	"Learning_Code/geecache/lru"
+	"container/list"
+	"time"
+)
+
+// 参考：Johnson & Shasha, "2Q: A Low Overhead High Performance Buffer Management Replacement Algorithm"
+
+const (
+	// recent队列占总容量的比例
+	defaultRecentRatio = 0.25
+	// ghost队列（只记录key）占总容量的比例
+	defaultGhostRatio = 0.5
+)
+
+// 2Q cache，对并发访问不安全
+// 新条目先进入recent队列，再次被访问时才晋升到frequent队列；
+// 从recent淘汰的key会记录在ghost队列中，短期内再次加入时直接进入frequent队列。
+// 这样一次性扫描的大量key只会冲刷recent队列，不会影响frequent中的热点数据。
+type Cache struct {
+	maxBytes      int64 // 最大容量
+	recentBytes   int64 // recent队列已用容量
+	frequentBytes int64 // frequent队列已用容量
+	ghostBytes    int64 // ghost队列记录的条目大小之和
+	recent        *list.List
+	frequent      *list.List
+	ghost         *list.List
+	cache         map[string]*list.Element // recent和frequent中的条目
+	ghosts        map[string]*list.Element // ghost中的条目
+	// 可选属性，在删除条目时执行的回调函数
+	OnEvicted func(key string, value Value)
+}
+
+// 与lru包共用Value定义，使不同淘汰策略可以互相替换
+type Value = lru.Value
+
+type entry struct {
+	key      string
+	value    Value
+	expire   time.Time // 过期时间，零值表示永不过期
+	frequent bool      // 是否位于frequent队列
+}
+
+// 判断条目在now时刻是否已过期
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && now.After(e.expire)
+}
+
+func (e *entry) size() int64 {
+	return int64(len(e.key)) + int64(e.value.Len())
+}
+
+// ghost队列中的条目，只保留key和条目大小
+type ghostEntry struct {
+	key  string
+	size int64
+}
+
+// 用于实例化的New()函数
+func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		recent:    list.New(),
+		frequent:  list.New(),
+		ghost:     list.New(),
+		cache:     make(map[string]*list.Element),
+		ghosts:    make(map[string]*list.Element),
+		OnEvicted: onEvicted,
+	}
+}
+
+// 查找功能，recent中的条目再次命中时晋升到frequent
+func (c *Cache) Get(key string) (value Value, ok bool) {
+	elem, ok := c.cache[key]
+	if !ok {
+		return
+	}
+	e := elem.Value.(*entry)
+	// 惰性过期：查找时发现条目已过期，直接删除并视为未命中
+	if e.expired(time.Now()) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.promote(elem)
+	return e.value, true
+}
+
+// 新增/修改，条目永不过期
+func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// 新增/修改，条目在expire时刻之后过期，expire为零值表示永不过期
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
+	if elem, ok := c.cache[key]; ok {
+		// 修改视为一次访问
+		e := elem.Value.(*entry)
+		c.resize(e, int64(value.Len())-int64(e.value.Len()))
+		e.value = value
+		e.expire = expire
+		c.promote(elem)
+	} else {
+		e := &entry{key: key, value: value, expire: expire}
+		// 最近被淘汰过的key，说明并非一次性访问，直接进入frequent
+		if g, ok := c.ghosts[key]; ok {
+			c.removeGhost(g)
+			e.frequent = true
+			c.cache[key] = c.frequent.PushFront(e)
+			c.frequentBytes += e.size()
+		} else {
+			c.cache[key] = c.recent.PushFront(e)
+			c.recentBytes += e.size()
+		}
+	}
+
+	// 如果超过Cache最大容量，需要删除条目
+	for c.maxBytes != 0 && c.maxBytes < c.recentBytes+c.frequentBytes {
+		c.RemoveOldest()
+	}
+}
+
+// 缓存淘汰：recent超出配额时淘汰recent中最旧的条目并记入ghost，否则淘汰frequent中最久未使用的条目
+func (c *Cache) RemoveOldest() {
+	recentTarget := int64(float64(c.maxBytes) * defaultRecentRatio)
+	if c.recent.Len() > 0 && (c.recentBytes > recentTarget || c.frequent.Len() == 0) {
+		elem := c.recent.Back()
+		c.removeElement(elem)
+		c.addGhost(elem.Value.(*entry))
+		return
+	}
+	if elem := c.frequent.Back(); elem != nil {
+		c.removeElement(elem)
+	}
+}
+
+// 删除key对应的条目，key不存在时什么也不做
+func (c *Cache) Remove(key string) {
+	if elem, ok := c.cache[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// 清理所有已过期的条目，供后台清理协程定期调用
+func (c *Cache) RemoveExpired() {
+	now := time.Now()
+	for _, elem := range c.cache {
+		if elem.Value.(*entry).expired(now) {
+			c.removeElement(elem)
+		}
+	}
+}
+
+// 按淘汰顺序遍历未过期的条目：先从旧到新遍历recent，再从旧到新遍历frequent，fn返回false时停止遍历
+func (c *Cache) Range(fn func(key string, value Value, expire time.Time) bool) {
+	now := time.Now()
+	for _, l := range []*list.List{c.recent, c.frequent} {
+		for elem := l.Back(); elem != nil; elem = elem.Prev() {
+			e := elem.Value.(*entry)
+			if e.expired(now) {
+				continue
+			}
+			if !fn(e.key, e.value, e.expire) {
+				return
+			}
+		}
+	}
+}
+
+// 返回加入key/value时首先会被淘汰的条目的key，不需要淘汰或key已存在时ok为false，供准入策略使用。
+// 新条目自身会被首先淘汰时也返回false
+func (c *Cache) Victim(key string, value Value) (victim string, ok bool) {
+	if _, exist := c.cache[key]; exist || c.maxBytes == 0 {
+		return
+	}
+	size := int64(len(key)) + int64(value.Len())
+	if c.recentBytes+c.frequentBytes+size <= c.maxBytes {
+		return
+	}
+	// 按加入新条目之后的状态，模拟 RemoveOldest 的选择
+	recentBytes, recentLen, frequentLen := c.recentBytes, c.recent.Len(), c.frequent.Len()
+	if _, ok := c.ghosts[key]; ok {
+		frequentLen++
+	} else {
+		recentBytes += size
+		recentLen++
+	}
+	recentTarget := int64(float64(c.maxBytes) * defaultRecentRatio)
+	elem := c.frequent.Back()
+	if recentLen > 0 && (recentBytes > recentTarget || frequentLen == 0) {
+		elem = c.recent.Back()
+	}
+	if elem == nil {
+		return
+	}
+	return elem.Value.(*entry).key, true
+}
+
+// 返回已用容量
+func (c *Cache) Bytes() int64 {
+	return c.recentBytes + c.frequentBytes
+}
+
+// 返回已经插入的条目数量
+func (c *Cache) Len() int {
+	return c.recent.Len() + c.frequent.Len()
+}
+
+// 将命中的条目移动到frequent队首
+func (c *Cache) promote(elem *list.Element) {
+	e := elem.Value.(*entry)
+	if e.frequent {
+		c.frequent.MoveToFront(elem)
+		return
+	}
+	c.recent.Remove(elem)
+	c.recentBytes -= e.size()
+	e.frequent = true
+	c.cache[e.key] = c.frequent.PushFront(e)
+	c.frequentBytes += e.size()
+}
+
+// 调整条目所在队列的已用容量
+func (c *Cache) resize(e *entry, delta int64) {
+	if e.frequent {
+		c.frequentBytes += delta
+	} else {
+		c.recentBytes += delta
+	}
+}
+
+// 从所在队列和字典中删除条目，并更新已用容量
+func (c *Cache) removeElement(elem *list.Element) {
+	e := elem.Value.(*entry)
+	if e.frequent {
+		c.frequent.Remove(elem)
+	} else {
+		c.recent.Remove(elem)
+	}
+	c.resize(e, -e.size())
+	delete(c.cache, e.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// 将被淘汰的key记入ghost，超出配额时丢弃最旧的记录
+func (c *Cache) addGhost(e *entry) {
+	c.ghosts[e.key] = c.ghost.PushFront(&ghostEntry{key: e.key, size: e.size()})
+	c.ghostBytes += e.size()
+	ghostTarget := int64(float64(c.maxBytes) * defaultGhostRatio)
+	for c.ghostBytes > ghostTarget && c.ghost.Len() > 0 {
+		c.removeGhost(c.ghost.Back())
+	}
+}
+
+func (c *Cache) removeGhost(elem *list.Element) {
+	g := elem.Value.(*ghostEntry)
+	c.ghost.Remove(elem)
+	delete(c.ghosts, g.key)
+	c.ghostBytes -= g.size
+}
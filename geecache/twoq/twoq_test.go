@@ -0,0 +1,125 @@
+package twoq
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+func TestCache_Get(t *testing.T) {
+	q := New(int64(0), nil)
+	q.Add("key1", String("1234"))
+	if v, ok := q.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := q.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+// 一次性扫描的key不会把被重复访问的热点key挤出缓存
+func TestScanResistance(t *testing.T) {
+	keys := make([]string, 0)
+	callback := func(key string, value Value) {
+		keys = append(keys, key)
+	}
+	// 每个条目4字节，容量可容纳4个条目
+	q := New(int64(16), callback)
+	q.Add("h1", String("v1"))
+	q.Add("h2", String("v2"))
+	q.Get("h1")
+	q.Get("h2")
+	for _, k := range []string{"s1", "s2", "s3", "s4", "s5"} {
+		q.Add(k, String("vv"))
+	}
+
+	if _, ok := q.Get("h1"); !ok {
+		t.Fatalf("hot key h1 should survive the scan")
+	}
+	if _, ok := q.Get("h2"); !ok {
+		t.Fatalf("hot key h2 should survive the scan")
+	}
+	expect := []string{"s1", "s2", "s3"}
+	if !reflect.DeepEqual(expect, keys) {
+		t.Fatalf("expect evicted keys equals to %s, got %s", expect, keys)
+	}
+}
+
+// 被淘汰进ghost的key再次加入时直接进入frequent
+func TestGhost(t *testing.T) {
+	q := New(int64(16), nil)
+	q.Add("k1", String("v1"))
+	q.Add("k2", String("v2"))
+	q.Add("k3", String("v3"))
+	q.Add("k4", String("v4"))
+	q.Add("k5", String("v5"))
+	if _, ok := q.ghosts["k1"]; !ok {
+		t.Fatalf("k1 should be remembered in ghost")
+	}
+	q.Add("k1", String("v1"))
+	if e := q.cache["k1"].Value.(*entry); !e.frequent {
+		t.Fatalf("k1 should be added to frequent")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	q := New(int64(0), nil)
+	q.AddWithExpire("key1", String("1"), time.Now().Add(-time.Second))
+	q.AddWithExpire("key2", String("2"), time.Now().Add(-time.Second))
+	q.Add("key3", String("3"))
+
+	if _, ok := q.Get("key1"); ok || q.Len() != 2 {
+		t.Fatalf("lazy expire key1 failed")
+	}
+	q.RemoveExpired()
+	if _, ok := q.Get("key3"); !ok || q.Len() != 1 {
+		t.Fatalf("RemoveExpired failed")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	q := New(int64(0), nil)
+	q.Add("key1", String("1234"))
+	q.Add("key2", String("5678"))
+	q.Remove("key1")
+	q.Remove("key3")
+	if _, ok := q.Get("key1"); ok || q.Len() != 1 {
+		t.Fatalf("Remove key1 failed")
+	}
+}
+
+func TestVictim(t *testing.T) {
+	keys := make([]string, 0)
+	callback := func(key string, value Value) {
+		keys = append(keys, key)
+	}
+	q := New(int64(16), callback)
+	q.Add("h1", String("v1"))
+	q.Add("h2", String("v2"))
+	q.Get("h1")
+	q.Get("h2")
+	q.Add("s1", String("v1"))
+	if _, ok := q.Victim("s2", String("v2")); ok {
+		t.Fatalf("no victim expected while there is room")
+	}
+	q.Add("s2", String("v2"))
+
+	// recent超出配额，淘汰recent中最旧的s1
+	victim, ok := q.Victim("s3", String("v3"))
+	q.Add("s3", String("v3"))
+	if !ok || victim != "s1" || !reflect.DeepEqual(keys, []string{"s1"}) {
+		t.Fatalf("victim should be s1, got %s, evicted %s", victim, keys)
+	}
+	// s1在ghost中，再次加入时进入frequent，淘汰的仍是recent中最旧的s2
+	victim, ok = q.Victim("s1", String("v1"))
+	q.Add("s1", String("v1"))
+	if !ok || victim != "s2" || !reflect.DeepEqual(keys, []string{"s1", "s2"}) {
+		t.Fatalf("victim should be s2, got %s, evicted %s", victim, keys)
+	}
+}